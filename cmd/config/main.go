@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"go-chi-boilerplate/src/config"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+const (
+	FORMAT_YAML = "yaml"
+	FORMAT_JSON = "json"
+)
+
+// Prints the effective config, with secrets redacted and every key annotated with its source.
+//
+//	Usage example:
+//		go run cmd/config/main.go --format=yaml
+//		go run cmd/config/main.go --format=json --config=config/config.yaml
+func main() {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	format := flags.String("format", FORMAT_YAML, "output format: yaml or json")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
+	}
+
+	// the remaining flags (--config, --host.port, ...) are handled by the loader
+	loader := config.NewLoader(config.Options{Args: os.Args[1:]})
+	cfg, err := loader.Load()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	entries := loader.Inspect(cfg)
+
+	var output []byte
+	switch *format {
+	case FORMAT_YAML:
+		output, err = yaml.Marshal(toYamlNode(entries))
+	case FORMAT_JSON:
+		output, err = json.MarshalIndent(toJsonMap(entries), "", "  ")
+		output = append(output, '\n')
+	default:
+		err = fmt.Errorf("invalid format %q, valid choices are: %s, %s", *format, FORMAT_YAML, FORMAT_JSON)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	os.Stdout.Write(output)
+}
+
+// toYamlNode nests the entries by key, annotating every value with its source as a comment
+func toYamlNode(entries []config.InspectEntry) *yaml.Node {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, entry := range entries {
+		parts := strings.Split(entry.Key, ".")
+		section := root
+		for _, part := range parts[:len(parts)-1] {
+			section = yamlSection(section, part)
+		}
+
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}
+		value := &yaml.Node{}
+		value.Encode(entry.Value)
+		if value.Kind == yaml.ScalarNode {
+			value.LineComment = entry.Source
+		} else {
+			key.LineComment = entry.Source
+		}
+		section.Content = append(section.Content, key, value)
+	}
+	return root
+}
+
+func yamlSection(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+
+	section := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, section)
+	return section
+}
+
+// toJsonMap nests the entries by key, every value being an object of value and source
+func toJsonMap(entries []config.InspectEntry) map[string]interface{} {
+	root := make(map[string]interface{})
+	for _, entry := range entries {
+		parts := strings.Split(entry.Key, ".")
+		section := root
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[part] = next
+			}
+			section = next
+		}
+		section[parts[len(parts)-1]] = map[string]interface{}{
+			"value":  entry.Value,
+			"source": entry.Source,
+		}
+	}
+	return root
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/sirupsen/logrus"
 	"go-chi-boilerplate/docs"
 	"go-chi-boilerplate/src/config"
 	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/migration"
+	"go-chi-boilerplate/src/database/mongoschema"
+	"go-chi-boilerplate/src/health"
 	"go-chi-boilerplate/src/internals/controller"
 	"go-chi-boilerplate/src/internals/repository"
 	"go-chi-boilerplate/src/internals/service"
@@ -14,84 +16,180 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
-func setSwaggerInfo() {
+func setSwaggerInfo(cfg config.Config) {
 	docs.SwaggerInfo.Title = "Microservice Template Golang Example"
 	docs.SwaggerInfo.Description = "Example boilerplate"
 	docs.SwaggerInfo.Version = "1.0"
 	docs.SwaggerInfo.BasePath = "/"
 	docs.SwaggerInfo.Schemes = []string{"https", "http"}
+	if cfg.Host.TLS.Enabled() {
+		docs.SwaggerInfo.Schemes = []string{"https"}
+	}
+}
+
+func setLogLevel(level string) {
+	if level == "" {
+		return
+	}
+
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		logrus.Errorf("invalid log level %q, error: %v", level, err)
+		return
+	}
+	logrus.SetLevel(logLevel)
 }
 
 func main() {
+	// canceled on SIGINT and SIGTERM, stopping the startup (datasource retries, migrations) as well as the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// load config
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	// initialize mongodb connection
-	databaseCollection := database.NewDatabaseCollection(cfg)
+	// google credentials for the SDKs
+	gcpCredentials, err := cfg.GCP.MaterializeCredentials()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// released when the startup fails, handed over to the shutdown hooks once the server runs
+	var startupCleanup []func(ctx context.Context) error
+	fatal := func(err error) {
+		for i := len(startupCleanup) - 1; i >= 0; i-- {
+			if err := startupCleanup[i](context.Background()); err != nil {
+				logrus.Errorf("error when cleaning up, error: %v", err)
+			}
+		}
+		logrus.Fatal(err)
+	}
+	startupCleanup = append(startupCleanup, gcpCredentials.Remove)
+
+	// config hot-reload
+	cfgHolder := config.NewHolder(cfg)
+	setLogLevel(cfg.LogLevel)
+	cfgHolder.Subscribe(func(event config.ChangeEvent) {
+		setLogLevel(event.New.LogLevel)
+	})
+	cfgHolder.Watch()
+	startupCleanup = append(startupCleanup, cfgHolder.Close)
+
+	// initialize database connections
+	databaseCollection, err := database.NewDatabaseCollection(ctx, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	startupCleanup = append(startupCleanup, databaseCollection.Close)
+
+	// apply the pending migrations, the advisory lock lets a single pod migrate at a time
+	migrationConfig := cfg.DataSource.Migration
+	if migrationConfig.Auto && databaseCollection.SqlDB != nil {
+		migrator := migration.NewMigrator(databaseCollection.SqlDB, cfg.DataSource.SqlDBConfig.Driver, migration.Source(migrationConfig.Dir), migrationConfig.Table)
+		if err := migrator.Up(ctx, 0); err != nil {
+			fatal(err)
+		}
+	}
+
+	// create the mongodb collections and indexes declared by the repositories
+	mongoDBConfig := cfg.DataSource.MongoDBConfig
+	if mongoDBConfig.SchemaReconcile && databaseCollection.MongoDB != nil {
+		changes, err := mongoschema.Reconcile(ctx, databaseCollection.MongoDB, repository.CollectionSpecs(), mongoschema.Options{
+			DropUndeclared: mongoDBConfig.DropUndeclaredIndexes,
+		})
+		mongoschema.LogChanges(changes)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	// health checks
+	healthRegistry := health.NewRegistry()
+	databaseCollection.RegisterHealthChecks(healthRegistry, time.Second*time.Duration(cfg.HealthCheckTimeout))
+
+	// pool statistics
+	poolStatsCollector := database.NewPoolStatsCollector(databaseCollection, time.Second*time.Duration(cfg.DataSource.PoolStatsInterval))
+	poolStatsCollector.Start()
+	startupCleanup = append(startupCleanup, poolStatsCollector.Stop)
+
+	// datasource monitor, re-establishing the datasources failing after startup
+	datasourceMonitor := databaseCollection.NewMonitor(cfg)
+	datasourceMonitor.Start()
+	startupCleanup = append(startupCleanup, datasourceMonitor.Stop)
 
 	// repositories
 	exampleRepo := repository.NewExampleRepository(databaseCollection)
 
-	// services
-	exampleService := service.NewExampleService(exampleRepo)
+	// services, the writes spanning several repositories run within txManager
+	txManager := database.NewTxManager(databaseCollection, cfg.DataSource.SqlDBConfig.TxMaxRetries)
+	exampleService := service.NewExampleService(txManager, exampleRepo)
 
 	// controllers
+	healthController := controller.NewHealthController(healthRegistry, poolStatsCollector)
 	exampleController := controller.NewExampleController(exampleService)
 
 	// set swagger info
-	setSwaggerInfo()
+	setSwaggerInfo(cfg)
 
 	// registering router
 	router := httpServer.RegisterRouter(
-		cfg,
+		cfgHolder,
+		healthController,
 		exampleController,
 		// register controllers in here
 	)
 
+	// registering admin router, served on its own listener
+	var adminRouter http.Handler
+	if cfg.Host.AdminEnabled() {
+		adminRouter = httpServer.RegisterAdminRouter(
+			cfgHolder,
+			healthController,
+		)
+	}
+
 	// running server
 	logrus.Println("[INFO] Loading server")
-	runServer(cfg, router)
+	runServer(ctx, cfg, router, adminRouter, cfgHolder, gcpCredentials, databaseCollection, poolStatsCollector, datasourceMonitor, fatal)
 }
 
-func runServer(cfg config.Config, route http.Handler) {
-	// The HTTP Server
-	server := &http.Server{
-		Addr:         fmt.Sprintf("%s:%s", cfg.Host.Address, cfg.Host.Port),
-		WriteTimeout: time.Second * time.Duration(cfg.Host.WriteTimeout),
-		ReadTimeout:  time.Second * time.Duration(cfg.Host.ReadTimeout),
-		IdleTimeout:  time.Second * time.Duration(cfg.Host.IdleTimeout),
-		Handler:      route,
+func runServer(
+	ctx context.Context,
+	cfg config.Config,
+	route http.Handler,
+	adminRoute http.Handler,
+	cfgHolder *config.Holder,
+	gcpCredentials *config.GCPCredentials,
+	databaseCollection database.DBCollection,
+	poolStatsCollector *database.PoolStatsCollector,
+	datasourceMonitor *database.Monitor,
+	fatal func(err error),
+) {
+	server, err := httpServer.NewServer(cfg, route, adminRoute)
+	if err != nil {
+		fatal(err)
 	}
 
-	// Run Server
-	go func() {
-		logrus.Printf("⇨ http server started on %s\n", server.Addr)
-		server.ListenAndServe()
-	}()
-
-	quit := make(chan os.Signal, 1)
-
-	signal.Notify(quit, os.Interrupt)
-	sig := <-quit
-	logrus.Println("received shutdown signal. Trying to shutdown gracefully", sig)
-
-	// Context
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Stop Server
-	logrus.Println("Stopping HTTP Server")
-	server.SetKeepAlivesEnabled(false)
-	err := server.Shutdown(ctx)
-	if err != nil {
-		logrus.Fatal("Failure while shutting down gracefully, errApp: ", err)
+	// shutdown hooks, executed in order after the server has drained
+	server.OnShutdown("config watcher", cfgHolder.Close)
+	server.OnShutdown("datasource monitor", datasourceMonitor.Stop)
+	server.OnShutdown("pool stats", poolStatsCollector.Stop)
+	server.OnShutdown("database", databaseCollection.Close)
+	server.OnShutdown("gcp credentials", gcpCredentials.Remove)
+	if gcpCredentials.Owned() {
+		// the file is removed on shutdown, a re-executed process writes its own
+		server.UnsetOnHandoff(config.ENV_GOOGLE_APPLICATION_CREDENTIALS)
 	}
 
-	logrus.Println("Shutdown gracefully completed")
+	// the shutdown hooks have run whatever the error
+	if err := server.Run(ctx); err != nil {
+		logrus.Fatal(err)
+	}
 }
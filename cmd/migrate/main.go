@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/migration"
+	"go-chi-boilerplate/src/tools"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	COMMAND_UP     = "up"
+	COMMAND_DOWN   = "down"
+	COMMAND_STATUS = "status"
+	COMMAND_GOTO   = "goto"
+	COMMAND_CREATE = "create"
+
+	// directory of the new migrations when MIGRATION_DIR is empty, embedded by the migrations package
+	defaultMigrationDir = "migrations"
+)
+
+// Migrates the postgres schema.
+//
+//	Usage example:
+//		go run cmd/migrate/main.go up          # apply every pending migration
+//		go run cmd/migrate/main.go up 1        # apply the next pending migration
+//		go run cmd/migrate/main.go down        # revert the last applied migration
+//		go run cmd/migrate/main.go down 0      # revert every applied migration
+//		go run cmd/migrate/main.go goto 20240101000000
+//		go run cmd/migrate/main.go status
+//		go run cmd/migrate/main.go create add_examples_index
+func main() {
+	flags := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
+	}
+	args := flags.Args()
+	if len(args) == 0 {
+		logrus.Fatalf("missing command, valid choices are: %s, %s, %s, %s, %s", COMMAND_UP, COMMAND_DOWN, COMMAND_STATUS, COMMAND_GOTO, COMMAND_CREATE)
+	}
+
+	// the flags (--config, --datasource.migration.dir, ...) are handled by the loader
+	cfg, err := config.LoadConfig("datasource.sql", "datasource.migration")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	migrationConfig := cfg.DataSource.Migration
+
+	if args[0] == COMMAND_CREATE {
+		if len(args) < 2 {
+			logrus.Fatal("missing migration name")
+		}
+		dir := migrationConfig.Dir
+		if dir == "" {
+			dir = defaultMigrationDir
+		}
+		upFile, downFile, err := migration.Create(dir, args[1])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		fmt.Printf("created %s\ncreated %s\n", upFile, downFile)
+		return
+	}
+
+	switch args[0] {
+	case COMMAND_UP, COMMAND_DOWN, COMMAND_GOTO, COMMAND_STATUS:
+	default:
+		logrus.Fatalf("invalid command %q, valid choices are: %s, %s, %s, %s, %s", args[0], COMMAND_UP, COMMAND_DOWN, COMMAND_STATUS, COMMAND_GOTO, COMMAND_CREATE)
+	}
+
+	sqlDBConfig := cfg.DataSource.SqlDBConfig
+	if !sqlDBConfig.Enabled {
+		logrus.Fatal("the SQL datasource is not enabled, set SQL_ENABLED=true")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.InitializeSqlDatabase(ctx, sqlDBConfig.Driver, sqlDBConfig.DSN(), tools.SqlPoolConfig{})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator := migration.NewMigrator(db, sqlDBConfig.Driver, migration.Source(migrationConfig.Dir), migrationConfig.Table)
+
+	switch args[0] {
+	case COMMAND_UP:
+		err = migrator.Up(ctx, intArg(args, 0))
+	case COMMAND_DOWN:
+		err = migrator.Down(ctx, intArg(args, 1))
+	case COMMAND_GOTO:
+		if len(args) < 2 {
+			logrus.Fatal("missing migration version")
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			logrus.Fatalf("invalid migration version %q", args[1])
+		}
+		err = migrator.Goto(ctx, version)
+	case COMMAND_STATUS:
+		err = printStatus(ctx, migrator)
+	}
+	if err != nil {
+		db.Close()
+		logrus.Fatal(err)
+	}
+}
+
+// intArg returns the numeric argument following the command, or defaultValue when there is none
+func intArg(args []string, defaultValue int) int {
+	if len(args) < 2 {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		logrus.Fatalf("invalid number %q", args[1])
+	}
+	return value
+}
+
+func printStatus(ctx context.Context, migrator migration.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
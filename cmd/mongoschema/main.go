@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/mongoschema"
+	"go-chi-boilerplate/src/internals/repository"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+// Reconciles the mongodb collections and indexes declared by the repositories (see repository.CollectionSpecs).
+//
+//	Usage example:
+//		go run cmd/mongoschema/main.go --dry-run            # report the changes and the drift
+//		go run cmd/mongoschema/main.go                      # create the missing collections and indexes
+//		go run cmd/mongoschema/main.go --drop-undeclared    # also drop the indexes no longer declared
+func main() {
+	flags := pflag.NewFlagSet("mongoschema", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	dryRun := flags.Bool("dry-run", false, "only report the changes")
+	dropUndeclared := flags.Bool("drop-undeclared", false, "drop the indexes that are not declared (overrides $MONGODB_DROP_UNDECLARED_INDEXES)")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
+	}
+
+	// the remaining flags (--config, --datasource.mongodb.url, ...) are handled by the loader
+	cfg, err := config.LoadConfig("datasource.mongodb")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	mongoDBConfig := cfg.DataSource.MongoDBConfig
+	if !mongoDBConfig.Enabled {
+		logrus.Fatal("mongodb is not enabled, set MONGODB_ENABLED=true")
+	}
+	if !flags.Lookup("drop-undeclared").Changed {
+		*dropUndeclared = mongoDBConfig.DropUndeclaredIndexes
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mongoDB, err := database.InitializeMongoDatabase(ctx, mongoDBConfig, nil, nil)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer mongoDB.Client().Disconnect(context.Background())
+
+	changes, err := mongoschema.Reconcile(ctx, mongoDB, repository.CollectionSpecs(), mongoschema.Options{
+		DryRun:         *dryRun,
+		DropUndeclared: *dropUndeclared,
+	})
+	printChanges(changes)
+	if err != nil {
+		mongoDB.Client().Disconnect(context.Background())
+		logrus.Fatal(err)
+	}
+}
+
+func printChanges(changes []mongoschema.Change) {
+	if len(changes) == 0 {
+		fmt.Println("mongodb schema is up to date")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTION\tINDEX\tACTION\tAPPLIED\tDETAIL")
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", change.Collection, change.Index, change.Action, change.Applied, change.Detail)
+	}
+	w.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/seed"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+const ENV_PROD = "PROD"
+
+// Seeds the postgres tables and mongodb collections with the fixtures of --dir and of its env set (e.g. fixtures/dev).
+// Fixtures with a key can be re-run, their rows are updated instead of inserted again.
+//
+//	Usage example:
+//		go run cmd/seed/main.go                        # seed fixtures and fixtures/<env>
+//		go run cmd/seed/main.go --env staging          # seed fixtures and fixtures/staging
+//		go run cmd/seed/main.go --truncate             # empty the seeded tables and collections first
+//		go run cmd/seed/main.go --truncate --cascade   # also empty the postgres tables referencing them
+func main() {
+	flags := pflag.NewFlagSet("seed", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	dir := flags.String("dir", "fixtures", "directory of the fixture files")
+	env := flags.String("env", "", "fixture set seeded after the shared one (defaults to $ENV)")
+	truncate := flags.Bool("truncate", false, "empty the seeded tables and collections before seeding")
+	cascade := flags.Bool("cascade", false, "with --truncate, also empty the postgres tables referencing the seeded ones")
+	force := flags.Bool("force", false, "allow seeding when $ENV is PROD")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
+	}
+
+	// the remaining flags (--config, --datasource.sql.host, ...) are handled by the loader
+	cfg, err := config.LoadConfig("env", "datasource")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if cfg.Env == ENV_PROD && !*force {
+		logrus.Fatal("refusing to seed a PROD environment, use --force")
+	}
+	if *env == "" {
+		*env = cfg.Env
+	}
+
+	fixtures, err := seed.Load(*dir, *env)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.NewDatabaseCollection(ctx, cfg)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer db.Close(context.Background())
+
+	results, err := seed.Seed(ctx, db, fixtures, seed.Options{Truncate: *truncate, Cascade: *cascade})
+	printResults(results)
+	if err != nil {
+		db.Close(context.Background())
+		logrus.Fatal(err)
+	}
+}
+
+func printResults(results []seed.Result) {
+	if len(results) == 0 {
+		fmt.Println("no fixture to seed")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASOURCE\tTABLE\tINSERTED\tUPDATED")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", result.Datasource, result.Table, result.Inserted, result.Updated)
+	}
+	w.Flush()
+}
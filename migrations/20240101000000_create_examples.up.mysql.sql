@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS examples (
+    id         BIGINT      NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+);
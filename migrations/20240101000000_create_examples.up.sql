@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS examples (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS examples (
+    id         INTEGER   PRIMARY KEY AUTOINCREMENT,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
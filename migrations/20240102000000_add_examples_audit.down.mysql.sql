@@ -0,0 +1,7 @@
+DROP INDEX idx_examples_deleted_at ON examples;
+
+ALTER TABLE examples
+    DROP COLUMN deleted_at,
+    DROP COLUMN updated_by,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_at;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_examples_deleted_at;
+
+ALTER TABLE examples
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS created_by,
+    DROP COLUMN IF EXISTS updated_at;
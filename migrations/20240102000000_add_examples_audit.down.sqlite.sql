@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_examples_deleted_at;
+
+ALTER TABLE examples DROP COLUMN deleted_at;
+ALTER TABLE examples DROP COLUMN updated_by;
+ALTER TABLE examples DROP COLUMN created_by;
+ALTER TABLE examples DROP COLUMN updated_at;
@@ -0,0 +1,7 @@
+ALTER TABLE examples
+    ADD COLUMN updated_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD COLUMN created_by VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN deleted_at DATETIME(6)  NULL;
+
+CREATE INDEX idx_examples_deleted_at ON examples (deleted_at);
@@ -0,0 +1,7 @@
+ALTER TABLE examples
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS created_by TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS updated_by TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_examples_deleted_at ON examples (deleted_at);
@@ -0,0 +1,15 @@
+-- sqlite can't add a column defaulting to CURRENT_TIMESTAMP, the table is rebuilt with the audit columns
+CREATE TABLE examples_audit (
+    id         INTEGER   PRIMARY KEY AUTOINCREMENT,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT      NOT NULL DEFAULT '',
+    updated_by TEXT      NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP
+);
+INSERT INTO examples_audit (id, name, created_at, updated_at) SELECT id, name, created_at, created_at FROM examples;
+DROP TABLE examples;
+ALTER TABLE examples_audit RENAME TO examples;
+
+CREATE INDEX IF NOT EXISTS idx_examples_deleted_at ON examples (deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE examples ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
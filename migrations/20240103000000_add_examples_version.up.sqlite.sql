@@ -0,0 +1 @@
+ALTER TABLE examples ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
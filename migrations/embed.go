@@ -0,0 +1,13 @@
+// Package migrations embeds the versioned SQL migrations, so the binaries don't depend on the working directory.
+//
+//	Files are named <version>_<name>.up.sql and <version>_<name>.down.sql, create them with:
+//		go run cmd/migrate/main.go create <name>
+//
+//	A file suffixed with a dialect replaces the unsuffixed one for that dialect, e.g. for sqlite:
+//		<version>_<name>.up.sqlite.sql
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
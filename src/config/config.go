@@ -1,39 +1,249 @@
 package config
 
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Config is loaded from layered sources, each one overriding the previous (see LoadConfig):
+//
+//	defaults < config/config.yaml < config/config.<env>.yaml < .env < environment variables < command-line flags
+//
+// The mapstructure tags describe the nested keys used in config files (e.g. host.tls.cert_file)
+// and command-line flags (e.g. --host.port), the alias tags a former name of a key still accepted,
+// the env tags the environment variables (and .env keys), followed by their former names,
+// the default tags the value used when no source sets the key,
+// and the secret tags the values that must never be printed (redacted by Inspect).
+//
+// Any string value can reference a secret instead of holding it (e.g. file:///run/secrets/pg), see ResolveSecret.
 type (
 	Config struct {
-		Env             string     `mapstructure:"ENV"`
-		SwaggerUsername string     `mapstructure:"SWAGGER_USERNAME"`
-		SwaggerPassword string     `mapstructure:"SWAGGER_PASSWORD"`
-		Host            Host       `mapstructure:",squash"`
-		DataSource      DataSource `mapstructure:",squash"`
+		Env                string     `mapstructure:"env" env:"ENV" default:"DEV" validate:"omitempty,oneof=DEV STAGING PROD"`
+		LogLevel           string     `mapstructure:"log_level" env:"LOG_LEVEL" default:"info" reload:"hot" validate:"omitempty,oneof=panic fatal error warn warning info debug trace"`
+		SwaggerUsername    string     `mapstructure:"swagger_username" env:"SWAGGER_USERNAME" reload:"hot" secret:"true"`
+		SwaggerPassword    string     `mapstructure:"swagger_password" env:"SWAGGER_PASSWORD" reload:"hot" secret:"true"`
+		HealthCheckTimeout int        `mapstructure:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2" validate:"min=0"`
+		Cors               Cors       `mapstructure:"cors"`
+		RateLimit          RateLimit  `mapstructure:"rate_limit"`
+		Host               Host       `mapstructure:"host"`
+		DataSource         DataSource `mapstructure:"datasource"`
+		GCP                GCP        `mapstructure:"gcp"`
+	}
+
+	// GCP holds the Google Cloud credentials (base64 encoded, or the JSON itself when resolved from a secret reference),
+	// materialised as a file by MaterializeCredentials
+	GCP struct {
+		CredentialsBase64 string `mapstructure:"credentials_base64" env:"GOOGLE_APPLICATION_CREDENTIALS_BASE64" secret:"true"`
+		CredentialsDir    string `mapstructure:"credentials_dir" env:"GOOGLE_APPLICATION_CREDENTIALS_DIR" validate:"omitempty,dir"`
+	}
+
+	Cors struct {
+		AllowedOrigins []string `mapstructure:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" default:"https://*,http://*" reload:"hot"`
+	}
+
+	// RateLimit limits the requests per client IP to Requests per Window seconds (a token bucket, so bursts up to Requests),
+	// disabled when Requests is 0. Behind the TrustedProxies the client IP is taken from X-Forwarded-For.
+	RateLimit struct {
+		Requests int `mapstructure:"requests" env:"RATE_LIMIT_REQUESTS" reload:"hot" validate:"min=0"`
+		Window   int `mapstructure:"window" env:"RATE_LIMIT_WINDOW" default:"60" reload:"hot" validate:"min=0"`
 	}
 
 	Host struct {
-		Address      string `mapstructure:"HOST_ADDRESS"`
-		Port         string `mapstructure:"HOST_PORT"`
-		WriteTimeout int    `mapstructure:"HOST_WRITE_TIMEOUT"`
-		ReadTimeout  int    `mapstructure:"HOST_READ_TIMEOUT"`
-		IdleTimeout  int    `mapstructure:"HOST_IDLE_TIMEOUT"`
+		Address         string `mapstructure:"address" env:"HOST_ADDRESS" default:"0.0.0.0"`
+		Port            string `mapstructure:"port" env:"HOST_PORT" default:"9090" validate:"required,numeric"`
+		WriteTimeout    int    `mapstructure:"write_timeout" env:"HOST_WRITE_TIMEOUT" default:"15" validate:"min=0"`
+		ReadTimeout     int    `mapstructure:"read_timeout" env:"HOST_READ_TIMEOUT" default:"15" validate:"min=0"`
+		IdleTimeout     int    `mapstructure:"idle_timeout" env:"HOST_IDLE_TIMEOUT" default:"60" validate:"min=0"`
+		ShutdownTimeout int    `mapstructure:"shutdown_timeout" env:"HOST_SHUTDOWN_TIMEOUT" default:"30" validate:"min=0"`
+		GracefulRestart bool   `mapstructure:"graceful_restart" env:"HOST_GRACEFUL_RESTART"`
+		RestartTimeout  int    `mapstructure:"restart_timeout" env:"HOST_RESTART_TIMEOUT" default:"60" validate:"min=0"`
+		AdminAddress    string `mapstructure:"admin_address" env:"HOST_ADMIN_ADDRESS"`
+		AdminPort       string `mapstructure:"admin_port" env:"HOST_ADMIN_PORT" validate:"omitempty,numeric,nefield=Port"`
+		// TrustedProxies are the CIDRs of the gateways and load balancers in front of the service,
+		// the only peers whose actor and X-Forwarded-For headers are believed
+		TrustedProxies []string `mapstructure:"trusted_proxies" env:"HOST_TRUSTED_PROXIES" reload:"hot" validate:"omitempty,dive,cidr"`
+		TLS            TLS      `mapstructure:"tls"`
+	}
+
+	TLS struct {
+		CertFile       string `mapstructure:"cert_file" env:"HOST_TLS_CERT_FILE" validate:"required_with=KeyFile,omitempty,file"`
+		KeyFile        string `mapstructure:"key_file" env:"HOST_TLS_KEY_FILE" validate:"required_with=CertFile,omitempty,file"`
+		MinVersion     string `mapstructure:"min_version" env:"HOST_TLS_MIN_VERSION" default:"1.2" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+		ClientCAFile   string `mapstructure:"client_ca_file" env:"HOST_TLS_CLIENT_CA_FILE" validate:"omitempty,file"`
+		ReloadInterval int    `mapstructure:"reload_interval" env:"HOST_TLS_RELOAD_INTERVAL" default:"60" validate:"min=0"`
 	}
 
 	DataSource struct {
-		PostgresDBConfig PostgresDBConfig `mapstructure:",squash"`
-		MongoDBConfig    MongoDBConfig    `mapstructure:",squash"`
+		PoolStatsInterval int             `mapstructure:"pool_stats_interval" env:"DB_POOL_STATS_INTERVAL" default:"60" validate:"min=0"`
+		MonitorInterval   int             `mapstructure:"monitor_interval" env:"DB_MONITOR_INTERVAL" default:"15" validate:"min=0"`
+		Retry             DataSourceRetry `mapstructure:"retry"`
+		QueryLog          QueryLog        `mapstructure:"query_log"`
+		Migration         Migration       `mapstructure:"migration"`
+		SqlDBConfig       SqlDBConfig     `mapstructure:"sql" alias:"postgres"`
+		MongoDBConfig     MongoDBConfig   `mapstructure:"mongodb"`
 	}
 
-	PostgresDBConfig struct {
-		Host     string `mapstructure:"POSTGRES_DB_HOST"`
-		User     string `mapstructure:"POSTGRES_DB_USER"`
-		Password string `mapstructure:"POSTGRES_DB_PASSWORD"`
-		Name     string `mapstructure:"POSTGRES_DB_NAME"`
-		Port     string `mapstructure:"POSTGRES_DB_PORT"`
-		SSLMode  string `mapstructure:"POSTGRES_SSL_MODE"`
-		Timezone string `mapstructure:"POSTGRES_TZ"`
+	// DataSourceRetry is the exponential backoff used to connect the datasources, durations in seconds.
+	// MaxAttempts 0 retries until the service is stopped.
+	DataSourceRetry struct {
+		MaxAttempts     int `mapstructure:"max_attempts" env:"DB_RETRY_MAX_ATTEMPTS" default:"10" validate:"min=0"`
+		InitialInterval int `mapstructure:"initial_interval" env:"DB_RETRY_INITIAL_INTERVAL" default:"1" validate:"min=1"`
+		MaxInterval     int `mapstructure:"max_interval" env:"DB_RETRY_MAX_INTERVAL" default:"30" validate:"gtefield=InitialInterval"`
 	}
 
+	// QueryLog logs the statements of every datasource with their duration, rows affected and request id, at debug level
+	// (see LOG_LEVEL) or warn level when slower than SlowThreshold (milliseconds, 0 never flags them). Parameters are redacted.
+	QueryLog struct {
+		Enabled       bool `mapstructure:"enabled" env:"DB_QUERY_LOG_ENABLED" default:"true"`
+		SlowThreshold int  `mapstructure:"slow_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200" validate:"min=0"`
+	}
+
+	// Migration runs the versioned SQL files of Dir against the SQL datasource, the ones embedded in the binary when Dir is empty.
+	// Auto applies the pending migrations at server startup.
+	Migration struct {
+		Dir   string `mapstructure:"dir" env:"MIGRATION_DIR" validate:"omitempty,dir"`
+		Table string `mapstructure:"table" env:"MIGRATION_TABLE" default:"schema_migrations" validate:"required"`
+		Auto  bool   `mapstructure:"auto" env:"MIGRATION_AUTO"`
+	}
+
+	// SqlDBConfig is the SQL datasource, required only when Enabled. SqlxEnabled and GormEnabled choose the handles
+	// exposed on its single pool (DBCollection.Sqlx and Gorm serve both anyway). Driver picks the database: postgres (lib/pq) or pgx for postgres, mysql, or sqlite,
+	// whose Name is the database file (:memory: for an in-memory database) and which needs no server.
+	// The former datasource.postgres keys and POSTGRES_* variables are still read, the sql ones win over them.
+	SqlDBConfig struct {
+		Enabled     bool   `mapstructure:"enabled" env:"SQL_ENABLED,POSTGRES_ENABLED" default:"true"`
+		SqlxEnabled bool   `mapstructure:"sqlx_enabled" env:"SQL_SQLX_ENABLED,POSTGRES_SQLX_ENABLED" default:"true"`
+		GormEnabled bool   `mapstructure:"gorm_enabled" env:"SQL_GORM_ENABLED,POSTGRES_GORM_ENABLED" default:"true"`
+		Driver      string `mapstructure:"driver" env:"SQL_DRIVER,POSTGRES_DRIVER" default:"postgres" validate:"oneof=postgres pgx mysql sqlite"`
+		Host        string `mapstructure:"host" env:"SQL_DB_HOST,POSTGRES_DB_HOST" validate:"required_if=Enabled true Driver postgres,required_if=Enabled true Driver pgx,required_if=Enabled true Driver mysql"`
+		User        string `mapstructure:"user" env:"SQL_DB_USER,POSTGRES_DB_USER" validate:"required_if=Enabled true Driver postgres,required_if=Enabled true Driver pgx,required_if=Enabled true Driver mysql"`
+		Password    string `mapstructure:"password" env:"SQL_DB_PASSWORD,POSTGRES_DB_PASSWORD" secret:"true"`
+		Name        string `mapstructure:"name" env:"SQL_DB_NAME,POSTGRES_DB_NAME" validate:"required_if=Enabled true"`
+		// Port defaults to the one of the driver (5432 for postgres, 3306 for mysql)
+		Port     string `mapstructure:"port" env:"SQL_DB_PORT,POSTGRES_DB_PORT" validate:"omitempty,numeric"`
+		SSLMode  string `mapstructure:"ssl_mode" env:"SQL_SSL_MODE,POSTGRES_SSL_MODE" validate:"omitempty,oneof=disable allow prefer require verify-ca verify-full"`
+		Timezone string `mapstructure:"timezone" env:"SQL_TZ,POSTGRES_TZ"`
+
+		// ReplicaHosts are read replicas (host or host:port, the port defaults to Port) sharing the credentials of the primary
+		ReplicaHosts []string `mapstructure:"replica_hosts" env:"SQL_REPLICA_HOSTS,POSTGRES_REPLICA_HOSTS" validate:"excluded_if=Driver sqlite,omitempty,dive,required"`
+
+		// Pool, durations in seconds (0 means unlimited), applied to both the sqlx and gorm handles
+		MaxOpenConns    int `mapstructure:"max_open_conns" env:"SQL_MAX_OPEN_CONNS,POSTGRES_MAX_OPEN_CONNS" default:"25" validate:"min=0"`
+		MaxIdleConns    int `mapstructure:"max_idle_conns" env:"SQL_MAX_IDLE_CONNS,POSTGRES_MAX_IDLE_CONNS" default:"25" validate:"min=0"`
+		ConnMaxLifetime int `mapstructure:"conn_max_lifetime" env:"SQL_CONN_MAX_LIFETIME,POSTGRES_CONN_MAX_LIFETIME" default:"300" validate:"min=0"`
+		ConnMaxIdleTime int `mapstructure:"conn_max_idle_time" env:"SQL_CONN_MAX_IDLE_TIME,POSTGRES_CONN_MAX_IDLE_TIME" validate:"min=0"`
+
+		// TxMaxRetries is the number of retries of a transaction failing on a serialization failure or a deadlock
+		TxMaxRetries int `mapstructure:"tx_max_retries" env:"SQL_TX_MAX_RETRIES,POSTGRES_TX_MAX_RETRIES" default:"3" validate:"min=0"`
+	}
+
+	// MongoDBConfig is required only when Enabled
 	MongoDBConfig struct {
-		ConnectionString string `mapstructure:"MONGODB_URL"`
-		DatabaseName     string `mapstructure:"MONGODB_DB_NAME"`
+		Enabled          bool   `mapstructure:"enabled" env:"MONGODB_ENABLED" default:"true"`
+		ConnectionString string `mapstructure:"url" env:"MONGODB_URL" secret:"true" validate:"required_if=Enabled true"`
+		DatabaseName     string `mapstructure:"db_name" env:"MONGODB_DB_NAME" validate:"required_if=Enabled true"`
+
+		// Pool & timeouts, durations in seconds (0 means the driver default)
+		MaxPoolSize            int      `mapstructure:"max_pool_size" env:"MONGODB_MAX_POOL_SIZE" default:"100" validate:"min=0"`
+		MinPoolSize            int      `mapstructure:"min_pool_size" env:"MONGODB_MIN_POOL_SIZE" validate:"min=0,ltefield=MaxPoolSize"`
+		MaxConnIdleTime        int      `mapstructure:"max_conn_idle_time" env:"MONGODB_MAX_CONN_IDLE_TIME" validate:"min=0"`
+		ConnectTimeout         int      `mapstructure:"connect_timeout" env:"MONGODB_CONNECT_TIMEOUT" default:"10" validate:"min=0"`
+		ServerSelectionTimeout int      `mapstructure:"server_selection_timeout" env:"MONGODB_SERVER_SELECTION_TIMEOUT" default:"30" validate:"min=0"`
+		Timeout                int      `mapstructure:"timeout" env:"MONGODB_TIMEOUT" validate:"min=0"`
+		Compressors            []string `mapstructure:"compressors" env:"MONGODB_COMPRESSORS" validate:"omitempty,dive,oneof=snappy zlib zstd"`
+
+		// Schema, reconciles the collections and indexes declared by the repositories at startup
+		SchemaReconcile       bool `mapstructure:"schema_reconcile" env:"MONGODB_SCHEMA_RECONCILE"`
+		DropUndeclaredIndexes bool `mapstructure:"drop_undeclared_indexes" env:"MONGODB_DROP_UNDECLARED_INDEXES"`
 	}
 )
+
+// AdminEnabled reports whether operational endpoints are served on their own listener
+func (h Host) AdminEnabled() bool {
+	return h.AdminPort != ""
+}
+
+// Enabled reports whether the server should terminate TLS itself
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// DSN returns the connection string of the primary for Driver: the key/value one understood by both the lib/pq and pgx
+// drivers, the go-sql-driver one for mysql, or the file URI of sqlite
+func (p SqlDBConfig) DSN() string {
+	return p.dsn(p.Host, p.port())
+}
+
+// DSNForHost returns the connection string of a replica host (host or host:port)
+func (p SqlDBConfig) DSNForHost(hostPort string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, p.port()
+	}
+	return p.dsn(host, port)
+}
+
+func (p SqlDBConfig) dsn(host, port string) string {
+	switch p.Driver {
+	case "mysql":
+		return p.mysqlDSN(host, port)
+	case "sqlite":
+		return p.sqliteDSN()
+	}
+
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+		host, p.User, p.Password, p.Name, port, p.SSLMode, p.Timezone,
+	)
+}
+
+// mysqlDSN parses the DATETIME columns as time.Time in Timezone (UTC by default), and makes UPDATE report the rows
+// it matched rather than the ones it changed, like postgres does
+func (p SqlDBConfig) mysqlDSN(host, port string) string {
+	params := url.Values{}
+	params.Set("parseTime", "true")
+	params.Set("clientFoundRows", "true")
+	if p.Timezone != "" {
+		params.Set("loc", p.Timezone)
+	}
+	switch p.SSLMode {
+	case "require":
+		params.Set("tls", "skip-verify")
+	case "verify-ca", "verify-full":
+		params.Set("tls", "true")
+	}
+
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", p.User, p.Password, net.JoinHostPort(host, port), p.Name, params.Encode())
+}
+
+// sqliteDSN enables the foreign keys, waits for the locks of the other connections instead of failing right away,
+// begins the transactions as writers so they don't deadlock when upgrading their lock, and stores the times in
+// the format of the sqlite date functions (e.g. CURRENT_TIMESTAMP).
+// The connections to :memory: share a single database, kept while any of them is open.
+func (p SqlDBConfig) sqliteDSN() string {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+
+	file := p.Name
+	if file == ":memory:" {
+		params.Set("cache", "shared")
+	} else {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	return fmt.Sprintf("file:%s?%s", file, params.Encode())
+}
+
+func (p SqlDBConfig) port() string {
+	if p.Port != "" {
+		return p.Port
+	}
+	if p.Driver == "mysql" {
+		return "3306"
+	}
+	return "5432"
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+const (
+	// ENV_GOOGLE_APPLICATION_CREDENTIALS is read by the Google SDKs to locate the credentials file
+	ENV_GOOGLE_APPLICATION_CREDENTIALS = "GOOGLE_APPLICATION_CREDENTIALS"
+
+	googleCredentialsDirPattern  = "google-application-credentials-"
+	googleCredentialsFilePattern = "google-application-credentials-*.json"
+)
+
+// GCPCredentials is the credentials file materialised by MaterializeCredentials
+type GCPCredentials struct {
+	Path string
+	// dir is the private directory created for the file, empty when the file was not written by us
+	dir string
+}
+
+// MaterializeCredentials writes the credentials into a file of a private (0700) directory and points
+// GOOGLE_APPLICATION_CREDENTIALS to it, so the Google SDKs can find them.
+// The credentials are either the service account JSON (e.g. resolved from a file:// or gcp:// reference) or its base64 encoding.
+// Nothing is done when no credentials are configured or GOOGLE_APPLICATION_CREDENTIALS is already set.
+//
+//	Usage example:
+//		credentials, err := cfg.GCP.MaterializeCredentials()
+//		...
+//		server.OnShutdown("gcp credentials", credentials.Remove)
+func (g GCP) MaterializeCredentials() (*GCPCredentials, error) {
+	if g.CredentialsBase64 == "" {
+		return nil, nil
+	}
+	if path := os.Getenv(ENV_GOOGLE_APPLICATION_CREDENTIALS); path != "" {
+		return &GCPCredentials{Path: path}, nil
+	}
+
+	credentials, err := decodeCredentials(g.CredentialsBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(g.CredentialsDir, googleCredentialsDirPattern)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating google application credentials directory, error: %w", err)
+	}
+	materialized := &GCPCredentials{dir: dir}
+
+	file, err := os.CreateTemp(dir, googleCredentialsFilePattern)
+	if err != nil {
+		materialized.Remove(context.Background())
+		return nil, fmt.Errorf("error when creating google application credentials file, error: %w", err)
+	}
+	materialized.Path = file.Name()
+	_, err = file.Write(credentials)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		materialized.Remove(context.Background())
+		return nil, fmt.Errorf("error when writing google application credentials, error: %w", err)
+	}
+
+	if err := os.Setenv(ENV_GOOGLE_APPLICATION_CREDENTIALS, materialized.Path); err != nil {
+		materialized.Remove(context.Background())
+		return nil, err
+	}
+	return materialized, nil
+}
+
+// Owned reports whether the credentials file was written by MaterializeCredentials, and so is removed by Remove.
+// A process re-executed for a graceful restart must then write its own copy, not inherit GOOGLE_APPLICATION_CREDENTIALS.
+func (c *GCPCredentials) Owned() bool {
+	return c != nil && c.dir != ""
+}
+
+// Remove deletes the credentials file and its directory, leaving a file we did not write untouched
+func (c *GCPCredentials) Remove(ctx context.Context) error {
+	if c == nil || c.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("error when removing google application credentials, error: %w", err)
+	}
+	return nil
+}
+
+// decodeCredentials returns the service account JSON, decoding it from base64 unless it already is JSON
+func decodeCredentials(value string) ([]byte, error) {
+	raw := bytes.TrimSpace([]byte(value))
+	if bytes.HasPrefix(raw, []byte("{")) {
+		return raw, nil
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("error when decoding google application credentials, error: %w", err)
+	}
+	return credentials, nil
+}
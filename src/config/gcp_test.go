@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGCPMaterializeCredentials(t *testing.T) {
+	const credentials = `{"type":"service_account"}`
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "base64", value: base64.StdEncoding.EncodeToString([]byte(credentials))},
+		{name: "json from a secret reference", value: "\n" + credentials + "\n"},
+		{name: "invalid", value: "not base64 nor json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ENV_GOOGLE_APPLICATION_CREDENTIALS, "")
+
+			materialized, err := GCP{CredentialsBase64: tt.value, CredentialsDir: t.TempDir()}.MaterializeCredentials()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("MaterializeCredentials() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !materialized.Owned() {
+				t.Error("Owned() = false, want true")
+			}
+			if got := os.Getenv(ENV_GOOGLE_APPLICATION_CREDENTIALS); got != materialized.Path {
+				t.Errorf("%s = %q, want %q", ENV_GOOGLE_APPLICATION_CREDENTIALS, got, materialized.Path)
+			}
+			content, err := os.ReadFile(materialized.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != credentials {
+				t.Errorf("credentials = %q, want %q", content, credentials)
+			}
+			info, err := os.Stat(filepath.Dir(materialized.Path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o700 {
+				t.Errorf("credentials directory mode = %o, want 700", perm)
+			}
+
+			if err := materialized.Remove(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Dir(materialized.Path)); !os.IsNotExist(err) {
+				t.Errorf("credentials directory not removed, error: %v", err)
+			}
+		})
+	}
+}
+
+// TestGCPMaterializeCredentialsInherited covers a process given GOOGLE_APPLICATION_CREDENTIALS, e.g. by its deployment
+func TestGCPMaterializeCredentialsInherited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ENV_GOOGLE_APPLICATION_CREDENTIALS, path)
+
+	credentials, err := GCP{CredentialsBase64: "e30="}.MaterializeCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Path != path || credentials.Owned() {
+		t.Errorf("MaterializeCredentials() = %+v, want %s not owned", credentials, path)
+	}
+	if err := credentials.Remove(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("inherited credentials removed, error: %v", err)
+	}
+}
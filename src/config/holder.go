@@ -0,0 +1,231 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TAG_RELOAD marks a config field that can be applied at runtime, without restarting the service.
+//
+//	Usage example:
+//		LogLevel string `mapstructure:"log_level" env:"LOG_LEVEL" reload:"hot"`
+const (
+	TAG_RELOAD = "reload"
+	RELOAD_HOT = "hot"
+)
+
+type (
+	// ChangeEvent describes a configuration change detected on disk.
+	//
+	//	Applied holds the keys that took effect immediately,
+	//	RestartRequired holds the keys that changed but only take effect after a restart.
+	ChangeEvent struct {
+		Old             Config
+		New             Config
+		Applied         []string
+		RestartRequired []string
+	}
+
+	Subscriber func(event ChangeEvent)
+
+	// Holder keeps the current configuration and publishes runtime-safe changes to its subscribers
+	Holder struct {
+		mu          sync.RWMutex
+		current     Config
+		subscribers []Subscriber
+
+		watcher *fsnotify.Watcher
+		// dirs are the watched directories, stamps the last seen state of every watched file
+		dirs   map[string]bool
+		stamps map[string]string
+	}
+)
+
+func NewHolder(cfg Config) *Holder {
+	return &Holder{
+		current: cfg,
+	}
+}
+
+// Get returns the configuration currently in effect
+func (h *Holder) Get() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.current
+}
+
+// Subscribe registers fn to be called after every applied change
+func (h *Holder) Subscribe(fn Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers = append(h.subscribers, fn)
+}
+
+// Watch reloads the configuration whenever one of the files read by LoadConfig (config files, .env) changes,
+// is created (e.g. the overlay of ENV) or removed.
+//
+// The directories of the files are watched and the files compared by the hash of their content on every event,
+// so kubernetes ConfigMap updates (an atomic swap of the ..data symlink) are picked up too, whatever their timestamps.
+func (h *Holder) Watch() {
+	loader := defaultLoader
+	if loader == nil || len(loader.Files()) == 0 {
+		logrus.Println("[INFO] no config file loaded, config hot-reload disabled")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logrus.Errorf("error when watching config files, config hot-reload disabled, error: %v", err)
+		return
+	}
+	h.watcher = watcher
+	h.dirs = make(map[string]bool)
+	h.refreshWatched(loader)
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !h.refreshWatched(loader) {
+					continue
+				}
+
+				cfg, err := loader.Load()
+				if err != nil {
+					logrus.Errorf("ignoring config change, error: %v", err)
+					continue
+				}
+				h.Update(cfg)
+				// the overlay files depend on the reloaded ENV
+				h.refreshWatched(loader)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.Errorf("error when watching config files, error: %v", err)
+			}
+		}
+	}()
+}
+
+// Close stops watching the config files
+func (h *Holder) Close(ctx context.Context) error {
+	if h.watcher == nil {
+		return nil
+	}
+	if err := h.watcher.Close(); err != nil {
+		return fmt.Errorf("error when closing the config watcher, error: %w", err)
+	}
+	return nil
+}
+
+// refreshWatched watches the directories of the files the loader depends on and reports whether any of them changed
+// since the last call
+func (h *Holder) refreshWatched(loader *Loader) (changed bool) {
+	stamps := make(map[string]string)
+	for _, file := range loader.Watched() {
+		file, _ = filepath.Abs(file)
+		stamps[file] = fileStamp(file)
+
+		dir := filepath.Dir(file)
+		if h.dirs[dir] {
+			continue
+		}
+		if err := h.watcher.Add(dir); err != nil {
+			logrus.Errorf("error when watching %s, error: %v", dir, err)
+			continue
+		}
+		h.dirs[dir] = true
+	}
+
+	changed = h.stamps != nil && !reflect.DeepEqual(h.stamps, stamps)
+	h.stamps = stamps
+	return changed
+}
+
+// fileStamp identifies the content of file (following symlinks) by its sha256, empty when the file does not exist
+func fileStamp(file string) string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// Update applies the runtime-safe fields of cfg, reports the ones that need a restart,
+// and notifies the subscribers when anything was applied
+func (h *Holder) Update(cfg Config) {
+	h.mu.Lock()
+	old := h.current
+	next := old
+	applied, restartRequired := mergeHotFields(reflect.ValueOf(&next).Elem(), reflect.ValueOf(cfg), "")
+	h.current = next
+	subscribers := make([]Subscriber, len(h.subscribers))
+	copy(subscribers, h.subscribers)
+	h.mu.Unlock()
+
+	if len(restartRequired) > 0 {
+		logrus.Warnf("config changed but requires a restart to take effect: %s", strings.Join(restartRequired, ", "))
+	}
+	if len(applied) == 0 {
+		return
+	}
+	logrus.Printf("[INFO] config reloaded: %s", strings.Join(applied, ", "))
+
+	event := ChangeEvent{
+		Old:             old,
+		New:             next,
+		Applied:         applied,
+		RestartRequired: restartRequired,
+	}
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// mergeHotFields copies every changed field tagged as hot-reloadable from src into dst,
+// returning the keys that were applied and the keys that changed but were left untouched
+func mergeHotFields(dst, src reflect.Value, prefix string) (applied []string, restartRequired []string) {
+	t := src.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(tagMapstructure), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			a, r := mergeHotFields(dst.Field(i), src.Field(i), name)
+			applied = append(applied, a...)
+			restartRequired = append(restartRequired, r...)
+			continue
+		}
+
+		if reflect.DeepEqual(dst.Field(i).Interface(), src.Field(i).Interface()) {
+			continue
+		}
+
+		if field.Tag.Get(TAG_RELOAD) != RELOAD_HOT {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+		applied = append(applied, name)
+	}
+
+	return applied, restartRequired
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHolderWatchConfigMapSwap swaps the ..data symlink the way kubernetes updates a mounted ConfigMap
+func TestHolderWatchConfigMapSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMapVersion(t, dir, "v1", "log_level: info\n")
+	if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultLoader = NewLoader(Options{ConfigFile: filepath.Join(dir, "config.yaml"), DotenvFile: filepath.Join(dir, ".env")})
+	t.Cleanup(func() { defaultLoader = nil })
+	cfg, err := defaultLoader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holder := NewHolder(cfg)
+	changes := make(chan ChangeEvent, 1)
+	holder.Subscribe(func(event ChangeEvent) { changes <- event })
+	holder.Watch()
+	t.Cleanup(func() { holder.Close(context.Background()) })
+
+	writeConfigMapVersion(t, dir, "v2", "log_level: debug\n")
+	if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-changes:
+		if event.New.LogLevel != "debug" {
+			t.Errorf("LogLevel = %q, want debug", event.New.LogLevel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not reloaded after the ..data symlink swap")
+	}
+}
+
+func TestFileStamp(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(name, content string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	link := func(name, target string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.Symlink(target, file); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	a := write("a.yaml", "log_level: info\n")
+	tests := []struct {
+		name      string
+		file      string
+		wantEqual bool
+	}{
+		{name: "same content", file: write("b.yaml", "log_level: info\n"), wantEqual: true},
+		{name: "same size and modification time", file: write("c.yaml", "log_level: warn\n")},
+		{name: "symlink to the same content", file: link("d.yaml", "b.yaml"), wantEqual: true},
+		{name: "symlink to another content", file: link("e.yaml", "c.yaml")},
+		{name: "missing", file: filepath.Join(dir, "missing.yaml")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileStamp(tt.file) == fileStamp(a); got != tt.wantEqual {
+				t.Errorf("fileStamp() equal = %v, want %v", got, tt.wantEqual)
+			}
+		})
+	}
+	if fileStamp(filepath.Join(dir, "missing.yaml")) != "" {
+		t.Error("fileStamp() of a missing file is not empty")
+	}
+}
+
+// testConfigYaml is the smallest config passing validation
+const testConfigYaml = `
+datasource:
+  sql:
+    host: localhost
+    user: postgres
+    name: example
+  mongodb:
+    url: mongodb://localhost:27017
+    db_name: example
+`
+
+func writeConfigMapVersion(t *testing.T, dir, version, content string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(content+testConfigYaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,29 +1,373 @@
 package config
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
+	"fmt"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/subosito/gotenv"
+	"go-chi-boilerplate/utils"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 )
 
-func LoadConfig() (config Config, err error) {
-	viper.AutomaticEnv()
+const (
+	ENV_CONFIG_FILE = "CONFIG_FILE"
+	FLAG_CONFIG     = "config"
 
-	// Check if the .env file exists
-	if _, err := os.Stat(".env"); err == nil {
-		viper.SetConfigFile(".env")
-		viper.SetConfigType("env")
+	defaultConfigDir  = "config"
+	defaultConfigName = "config"
+	defaultDotenvFile = ".env"
+)
+
+var configExtensions = []string{"yaml", "yml", "json"}
+
+type (
+	Options struct {
+		// Args are the command-line flags (e.g. os.Args[1:]), unknown flags are ignored
+		Args []string
+		// ConfigFile is the base config file, defaults to --config, $CONFIG_FILE or config/config.{yaml,yml,json}
+		ConfigFile string
+		// DotenvFile defaults to .env
+		DotenvFile string
+		// Sections are the keys (e.g. datasource.sql) whose values Load validates, the whole config when empty,
+		// so a command only fails on the values it uses
+		Sections []string
+	}
+
+	// Loader merges every config source in precedence order, lowest first:
+	//
+	//	1. defaults (default tags)
+	//	2. base config file (config/config.yaml)
+	//	3. overlay config file chosen by ENV, next to the base file (config/config.dev.yaml, config/config.prod.yaml, ...)
+	//	4. .env file
+	//	5. environment variables
+	//	6. command-line flags (e.g. --host.port=9090)
+	Loader struct {
+		options Options
+		files   []string
+		sources map[string]string
+		// watched are the files the last Load read or looked for (e.g. the overlays of ENV), see Holder.Watch
+		watched []string
+	}
+)
+
+// defaultLoader is the loader used by LoadConfig, kept to reload the config when its files change
+var defaultLoader *Loader
+
+// LoadConfig loads the config of the running binary, reading its flags from os.Args,
+// and validates its sections (see Options.Sections)
+func LoadConfig(sections ...string) (config Config, err error) {
+	defaultLoader = NewLoader(Options{Args: os.Args[1:], Sections: sections})
+	return defaultLoader.Load()
+}
+
+func NewLoader(options Options) *Loader {
+	if options.DotenvFile == "" {
+		options.DotenvFile = defaultDotenvFile
+	}
+	return &Loader{
+		options: options,
+	}
+}
+
+// Files returns the files read by the last Load, lowest precedence first
+func (l *Loader) Files() []string {
+	return l.files
+}
+
+// Watched returns the files the last Load read or looked for, the ones a change of requires a reload
+func (l *Loader) Watched() []string {
+	return l.watched
+}
+
+func (l *Loader) Load() (config Config, err error) {
+	v := viper.New()
+
+	// do viper bind
+	ViperBind(v)
+
+	flags, err := bindFlags(v, l.options.Args)
+	if err != nil {
+		return config, err
+	}
+
+	var files []string
+
+	// base config file
+	baseFile := l.baseFile(flags)
+	if baseFile != "" {
+		v.SetConfigFile(baseFile)
+		if err := v.ReadInConfig(); err != nil {
+			return config, fmt.Errorf("error when reading config file %s: %w", baseFile, err)
+		}
+		files = append(files, baseFile)
+	}
+
+	// .env, merged before the overlay only to resolve ENV
+	dotenvValues, err := readDotenv(l.options.DotenvFile)
+	if err != nil {
+		return config, err
+	}
+	dotenv := dotenvConfigMap(dotenvValues)
+	if err := v.MergeConfigMap(dotenv); err != nil {
+		return config, err
+	}
+
+	// overlay config file
+	overlayFiles := overlayCandidates(baseFile, v.GetString("env"))
+	overlayFile := findOverlayFile(baseFile, v.GetString("env"))
+	if overlayFile != "" {
+		v.SetConfigFile(overlayFile)
+		if err := v.MergeInConfig(); err != nil {
+			return config, fmt.Errorf("error when reading config file %s: %w", overlayFile, err)
+		}
+		files = append(files, overlayFile)
 
-		if err := viper.ReadInConfig(); err != nil {
-			logrus.Error("error load .env file")
+		// .env overrides the overlay
+		if err := v.MergeConfigMap(dotenv); err != nil {
 			return config, err
 		}
 	}
 
-	// do viper bind
-	ViperBind()
+	// former keys of the config files (e.g. datasource.postgres.host)
+	if err := mergeKeyAliases(v); err != nil {
+		return config, err
+	}
+
+	if _, err := os.Stat(l.options.DotenvFile); err == nil {
+		files = append(files, l.options.DotenvFile)
+	}
+	l.files = files
+	l.watched = append(append(append([]string{}, files...), overlayFiles...), l.options.DotenvFile)
+	l.sources = trackSources(flags, dotenvValues, baseFile, overlayFile)
+
+	if err := v.Unmarshal(&config); err != nil {
+		return config, err
+	}
+
+	// replace the secret references (file://, base64://, env://, registered providers)
+	if err := resolveSecrets(context.Background(), &config); err != nil {
+		return config, err
+	}
+
+	// report every invalid value at once, before any datasource is opened
+	if err := config.Validate(l.options.Sections...); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the config against the validate tags, reporting every problem in one error.
+// Given sections (e.g. datasource.sql, env), only their values are checked.
+func (c Config) Validate(sections ...string) error {
+	namespaces := make([]string, 0, len(sections))
+	for _, section := range sections {
+		namespace, err := sectionNamespace(section)
+		if err != nil {
+			return err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+
+	if err := utils.ValidateStructNamespaces(c, tagEnv, namespaces...); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	return nil
+}
+
+// sectionNamespace returns the struct namespace of the key (e.g. Config.DataSource.SqlDBConfig for datasource.sql)
+func sectionNamespace(key string) (string, error) {
+	t := reflect.TypeOf(Config{})
+	namespace := t.Name()
+	for _, name := range strings.Split(key, ".") {
+		field, ok := fieldByKey(t, name)
+		if !ok {
+			return "", fmt.Errorf("unknown config section %q", key)
+		}
+		namespace += "." + field.Name
+		t = field.Type
+	}
+	return namespace, nil
+}
+
+// fieldByKey returns the field of the struct t named name, or its former name, in the config files
+func fieldByKey(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get(tagMapstructure), ",")[0] == name || field.Tag.Get(tagAlias) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func (l *Loader) baseFile(flags *pflag.FlagSet) string {
+	if configFlag := flags.Lookup(FLAG_CONFIG); configFlag.Changed {
+		return configFlag.Value.String()
+	}
+	if l.options.ConfigFile != "" {
+		return l.options.ConfigFile
+	}
+	if configFile := os.Getenv(ENV_CONFIG_FILE); configFile != "" {
+		return configFile
+	}
+
+	for _, ext := range configExtensions {
+		file := filepath.Join(defaultConfigDir, fmt.Sprintf("%s.%s", defaultConfigName, ext))
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// findOverlayFile returns the overlay of env next to the base file (config.yaml -> config.prod.yaml), if any
+func findOverlayFile(baseFile, env string) string {
+	for _, file := range overlayCandidates(baseFile, env) {
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// overlayCandidates returns the overlay files of env looked for next to the base file, in order
+func overlayCandidates(baseFile, env string) []string {
+	if env == "" {
+		return nil
+	}
+
+	dir, name := defaultConfigDir, defaultConfigName
+	if baseFile != "" {
+		dir = filepath.Dir(baseFile)
+		name = strings.TrimSuffix(filepath.Base(baseFile), filepath.Ext(baseFile))
+	}
+
+	candidates := make([]string, len(configExtensions))
+	for i, ext := range configExtensions {
+		candidates[i] = filepath.Join(dir, fmt.Sprintf("%s.%s.%s", name, strings.ToLower(env), ext))
+	}
+	return candidates
+}
+
+// readDotenv reads the variables of the .env file, if any
+func readDotenv(file string) (map[string]string, error) {
+	if _, err := os.Stat(file); err != nil {
+		return map[string]string{}, nil
+	}
+
+	values, err := gotenv.Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading %s: %w", file, err)
+	}
+	return values, nil
+}
+
+// dotenvConfigMap maps the .env variables (or their former names) onto the nested keys of Config, ignoring unknown variables
+func dotenvConfigMap(values map[string]string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, field := range configFields() {
+		for _, env := range field.Envs {
+			if value, ok := values[env]; ok {
+				setNestedKey(result, field.Key, value)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// mergeKeyAliases copies the values the config files set under a former key onto the key of Config,
+// unless they set the key itself
+func mergeKeyAliases(v *viper.Viper) error {
+	aliased := make(map[string]interface{})
+	for _, field := range configFields() {
+		if v.InConfig(field.Key) {
+			continue
+		}
+		for _, alias := range field.Aliases {
+			if v.InConfig(alias) {
+				setNestedKey(aliased, field.Key, v.Get(alias))
+				break
+			}
+		}
+	}
+
+	if len(aliased) == 0 {
+		return nil
+	}
+	return v.MergeConfigMap(aliased)
+}
+
+// setNestedKey sets the nested key (e.g. host.tls.cert_file) of config
+func setNestedKey(config map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	section := config
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := section[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			section[part] = next
+		}
+		section = next
+	}
+	section[parts[len(parts)-1]] = value
+}
+
+// bindFlags declares a flag for every key of Config (e.g. --host.port) and binds it to viper
+func bindFlags(v *viper.Viper, args []string) (*pflag.FlagSet, error) {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.String(FLAG_CONFIG, "", fmt.Sprintf("base config file (overrides $%s)", ENV_CONFIG_FILE))
+
+	fields := configFields()
+	for _, field := range fields {
+		usage := fmt.Sprintf("overrides $%s", field.Env)
+		for _, name := range append([]string{field.Key}, field.Aliases...) {
+			switch field.Field.Type.Kind() {
+			case reflect.Bool:
+				flags.Bool(name, false, usage)
+			case reflect.Int:
+				flags.Int(name, 0, usage)
+			case reflect.Slice:
+				flags.StringSlice(name, nil, usage)
+			default:
+				flags.String(name, "", usage)
+			}
+		}
+		for _, alias := range field.Aliases {
+			flags.MarkDeprecated(alias, fmt.Sprintf("use --%s", field.Key))
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("error when parsing flags: %w", err)
+	}
 
-	err = viper.Unmarshal(&config)
+	for _, field := range fields {
+		// only flags explicitly set override the other sources
+		if flag := changedFlag(flags, field); flag != nil {
+			v.BindPFlag(field.Key, flag)
+		}
+	}
 
-	return config, err
+	return flags, nil
+}
+
+// changedFlag returns the flag of field set on the command line, by its key or else by a former one, nil when none is
+func changedFlag(flags *pflag.FlagSet, field configField) *pflag.Flag {
+	for _, name := range append([]string{field.Key}, field.Aliases...) {
+		if flag := flags.Lookup(name); flag.Changed {
+			return flag
+		}
+	}
+	return nil
 }
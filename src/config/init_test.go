@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoaderLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		env          map[string]string
+		args         []string
+		wantHost     string
+		wantPassword string
+		wantErr      string
+	}{
+		{
+			name:     "base file",
+			files:    map[string]string{"config.yaml": "datasource: {sql: {host: base}}"},
+			wantHost: "base",
+		},
+		{
+			name: "overlay of ENV over the base file",
+			files: map[string]string{
+				"config.yaml":         "env: STAGING\ndatasource: {sql: {host: base}}",
+				"config.staging.yaml": "datasource: {sql: {host: overlay}}",
+			},
+			wantHost: "overlay",
+		},
+		{
+			name: ".env over the overlay",
+			files: map[string]string{
+				"config.yaml":         "env: STAGING\ndatasource: {sql: {host: base}}",
+				"config.staging.yaml": "datasource: {sql: {host: overlay}}",
+				".env":                "SQL_DB_HOST=dotenv",
+			},
+			wantHost: "dotenv",
+		},
+		{
+			name:     "environment over .env",
+			files:    map[string]string{"config.yaml": "", ".env": "SQL_DB_HOST=dotenv"},
+			env:      map[string]string{"SQL_DB_HOST": "env"},
+			wantHost: "env",
+		},
+		{
+			name:     "flag over the environment",
+			files:    map[string]string{"config.yaml": ""},
+			env:      map[string]string{"SQL_DB_HOST": "env"},
+			args:     []string{"--datasource.sql.host=flag"},
+			wantHost: "flag",
+		},
+		{
+			name:     "former key",
+			files:    map[string]string{"config.yaml": "datasource: {postgres: {host: former}}"},
+			wantHost: "former",
+		},
+		{
+			name:     "key over its former one",
+			files:    map[string]string{"config.yaml": "datasource: {sql: {host: base}, postgres: {host: former}}"},
+			wantHost: "base",
+		},
+		{
+			name:     "former variable",
+			files:    map[string]string{"config.yaml": ""},
+			env:      map[string]string{"POSTGRES_DB_HOST": "former"},
+			wantHost: "former",
+		},
+		{
+			name:     "variable over its former one",
+			files:    map[string]string{"config.yaml": ""},
+			env:      map[string]string{"SQL_DB_HOST": "env", "POSTGRES_DB_HOST": "former"},
+			wantHost: "env",
+		},
+		{
+			name:     "former variable in .env",
+			files:    map[string]string{"config.yaml": "", ".env": "POSTGRES_DB_HOST=former"},
+			wantHost: "former",
+		},
+		{
+			name:     "former flag",
+			files:    map[string]string{"config.yaml": ""},
+			args:     []string{"--datasource.postgres.host=former"},
+			wantHost: "former",
+		},
+		{
+			name:         "base64 secret",
+			files:        map[string]string{"config.yaml": "datasource: {sql: {host: base}}"},
+			env:          map[string]string{"SQL_DB_PASSWORD": "base64://cGFzc3dvcmQ="},
+			wantPassword: "password",
+		},
+		{
+			name:         "env secret",
+			files:        map[string]string{"config.yaml": "datasource: {sql: {host: base}}"},
+			env:          map[string]string{"SQL_DB_PASSWORD": "env://TEST_PG_PASSWORD", "TEST_PG_PASSWORD": "from env"},
+			wantPassword: "from env",
+		},
+		{
+			name:         "unknown secret scheme is kept",
+			files:        map[string]string{"config.yaml": "datasource: {sql: {host: base}}"},
+			env:          map[string]string{"SQL_DB_PASSWORD": "unknown://ref"},
+			wantPassword: "unknown://ref",
+		},
+		{
+			name:    "invalid value",
+			files:   map[string]string{"config.yaml": "datasource: {sql: {host: base, driver: oracle}}"},
+			wantErr: "SQL_DRIVER valid choices",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// the values validation requires besides the host
+			for key, value := range testRequiredEnv {
+				t.Setenv(key, value)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			loader := NewLoader(Options{
+				Args:       tt.args,
+				ConfigFile: filepath.Join(dir, "config.yaml"),
+				DotenvFile: filepath.Join(dir, ".env"),
+			})
+			cfg, err := loader.Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantHost != "" && cfg.DataSource.SqlDBConfig.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", cfg.DataSource.SqlDBConfig.Host, tt.wantHost)
+			}
+			if tt.wantPassword != "" && cfg.DataSource.SqlDBConfig.Password != tt.wantPassword {
+				t.Errorf("Password = %q, want %q", cfg.DataSource.SqlDBConfig.Password, tt.wantPassword)
+			}
+		})
+	}
+}
+
+// testRequiredEnv are the values validation requires besides the SQL host
+var testRequiredEnv = map[string]string{
+	"SQL_DB_USER":     "postgres",
+	"SQL_DB_NAME":     "example",
+	"MONGODB_URL":     "mongodb://localhost:27017",
+	"MONGODB_DB_NAME": "example",
+}
+
+func TestConfigValidateSections(t *testing.T) {
+	// an invalid host port, a missing migration table and an unknown driver
+	cfg := Config{}
+	cfg.Host.Port = "http"
+	cfg.DataSource.Migration.Table = ""
+	cfg.DataSource.SqlDBConfig.Driver = "oracle"
+
+	tests := []struct {
+		name     string
+		sections []string
+		want     []string
+		wantNot  []string
+		wantErr  string
+	}{
+		{name: "whole config", want: []string{"HOST_PORT", "MIGRATION_TABLE", "SQL_DRIVER"}},
+		{name: "one section", sections: []string{"datasource.migration"}, want: []string{"MIGRATION_TABLE"}, wantNot: []string{"HOST_PORT", "SQL_DRIVER"}},
+		{name: "nested sections", sections: []string{"datasource"}, want: []string{"MIGRATION_TABLE", "SQL_DRIVER"}, wantNot: []string{"HOST_PORT"}},
+		{name: "former key", sections: []string{"datasource.postgres"}, want: []string{"SQL_DRIVER"}, wantNot: []string{"HOST_PORT", "MIGRATION_TABLE"}},
+		{name: "valid section", sections: []string{"datasource.mongodb"}},
+		{name: "unknown section", sections: []string{"datasource.redis"}, wantErr: `unknown config section "datasource.redis"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfg.Validate(tt.sections...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want %s reported", err, want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(err.Error(), wantNot) {
+					t.Errorf("Validate() error = %v, want %s left out", err, wantNot)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Sources of a config value, see Loader for their precedence
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_FILE    = "file"
+	SOURCE_DOTENV  = ".env"
+	SOURCE_ENV     = "env"
+	SOURCE_FLAG    = "flag"
+
+	REDACTED  = "******"
+	tagSecret = "secret"
+)
+
+type (
+	// InspectEntry is a key of the effective config, annotated with the source its value came from
+	InspectEntry struct {
+		Key    string      `json:"key"`
+		Value  interface{} `json:"value"`
+		Source string      `json:"source"`
+	}
+)
+
+// Inspect returns every key of cfg (as loaded by the last Load) with its value and source.
+// Values of fields tagged secret are redacted.
+func (l *Loader) Inspect(cfg Config) []InspectEntry {
+	values := make(map[string]reflect.Value)
+	collectConfigValues(reflect.ValueOf(cfg), "", values)
+
+	fields := configFields()
+	entries := make([]InspectEntry, 0, len(fields))
+	for _, field := range fields {
+		var value interface{} = values[field.Key].Interface()
+		if field.Field.Tag.Get(tagSecret) == "true" && !values[field.Key].IsZero() {
+			value = REDACTED
+		}
+
+		source, ok := l.sources[field.Key]
+		if !ok {
+			source = SOURCE_DEFAULT
+		}
+
+		entries = append(entries, InspectEntry{
+			Key:    field.Key,
+			Value:  value,
+			Source: source,
+		})
+	}
+
+	return entries
+}
+
+func collectConfigValues(v reflect.Value, prefix string, values map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get(tagMapstructure), ",")[0]
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if v.Field(i).Kind() == reflect.Struct {
+			collectConfigValues(v.Field(i), key, values)
+			continue
+		}
+		values[key] = v.Field(i)
+	}
+}
+
+// trackSources returns the source of every key that is not a default, following the Loader precedence
+func trackSources(flags *pflag.FlagSet, dotenv map[string]string, baseFile, overlayFile string) map[string]string {
+	baseKeys := readFileKeys(baseFile)
+	overlayKeys := readFileKeys(overlayFile)
+
+	sources := make(map[string]string)
+	for _, field := range configFields() {
+		env, inEnv := lookupEnvs(field.Envs, func(env string) bool {
+			_, ok := os.LookupEnv(env)
+			return ok
+		})
+		_, inDotenv := lookupEnvs(field.Envs, func(env string) bool {
+			_, ok := dotenv[env]
+			return ok
+		})
+		keys := append([]string{field.Key}, field.Aliases...)
+
+		switch flag := changedFlag(flags, field); {
+		case flag != nil:
+			sources[field.Key] = fmt.Sprintf("%s:--%s", SOURCE_FLAG, flag.Name)
+		case inEnv:
+			sources[field.Key] = fmt.Sprintf("%s:%s", SOURCE_ENV, env)
+		case inDotenv:
+			sources[field.Key] = SOURCE_DOTENV
+		case slices.ContainsFunc(keys, func(key string) bool { return overlayKeys[key] }):
+			sources[field.Key] = fmt.Sprintf("%s:%s", SOURCE_FILE, overlayFile)
+		case slices.ContainsFunc(keys, func(key string) bool { return baseKeys[key] }):
+			sources[field.Key] = fmt.Sprintf("%s:%s", SOURCE_FILE, baseFile)
+		}
+	}
+
+	return sources
+}
+
+// lookupEnvs returns the first of envs (a variable and its former names) found by lookup
+func lookupEnvs(envs []string, lookup func(env string) bool) (string, bool) {
+	for _, env := range envs {
+		if lookup(env) {
+			return env, true
+		}
+	}
+	return "", false
+}
+
+func readFileKeys(file string) map[string]bool {
+	keys := make(map[string]bool)
+	if file == "" {
+		return keys
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return keys
+	}
+	for _, key := range v.AllKeys() {
+		keys[key] = true
+	}
+	return keys
+}
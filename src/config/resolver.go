@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in secret schemes, a config value referencing one of them is replaced by the resolved secret.
+//
+//	Usage example:
+//		SQL_DB_PASSWORD=file:///run/secrets/pg
+//		SQL_DB_PASSWORD=base64://cGFzc3dvcmQ=
+//		SQL_DB_PASSWORD=env://PG_PASSWORD
+const (
+	SECRET_SCHEME_FILE   = "file"
+	SECRET_SCHEME_BASE64 = "base64"
+	SECRET_SCHEME_ENV    = "env"
+)
+
+type (
+	// SecretResolver resolves a secret reference of the scheme it is registered for.
+	// ref is the reference without its scheme (e.g. "/run/secrets/pg" for "file:///run/secrets/pg").
+	//
+	//	Register a provider (vault, secret manager, etc.) before loading the config:
+	//		config.RegisterSecretResolver("vault", vaultResolver)
+	SecretResolver interface {
+		Resolve(ctx context.Context, ref string) (string, error)
+	}
+
+	SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+	// MapSecretResolver resolves references from an in-memory map,
+	// standing in for a vault-like provider in local development and tests
+	MapSecretResolver map[string]string
+)
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		SECRET_SCHEME_FILE:   SecretResolverFunc(resolveFileSecret),
+		SECRET_SCHEME_BASE64: SecretResolverFunc(resolveBase64Secret),
+		SECRET_SCHEME_ENV:    SecretResolverFunc(resolveEnvSecret),
+	}
+)
+
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+func (m MapSecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	value, ok := m[ref]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", ref)
+	}
+	return value, nil
+}
+
+// RegisterSecretResolver registers resolver for the references of scheme (e.g. "vault" for vault://secret/data/pg/password),
+// replacing any resolver previously registered for it
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+// ResolveSecret resolves value when it references a registered scheme, otherwise it is returned as is
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+
+	secretResolversMu.RLock()
+	resolver, ok := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	if !ok {
+		// not a secret reference (e.g. mongodb://...)
+		return value, nil
+	}
+
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("error when resolving %s secret: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// resolveSecrets replaces every string field of cfg referencing a secret, reporting every failure at once
+func resolveSecrets(ctx context.Context, cfg *Config) error {
+	var errs []string
+	resolveSecretFields(ctx, reflect.ValueOf(cfg).Elem(), "", &errs)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid config secrets:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func resolveSecretFields(ctx context.Context, v reflect.Value, prefix string, errs *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get(tagMapstructure), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fieldValue := v.Field(i)
+		switch fieldValue.Kind() {
+		case reflect.Struct:
+			resolveSecretFields(ctx, fieldValue, key, errs)
+		case reflect.String:
+			secret, err := ResolveSecret(ctx, fieldValue.String())
+			if err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			fieldValue.SetString(secret)
+		}
+	}
+}
+
+func resolveFileSecret(ctx context.Context, ref string) (string, error) {
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func resolveBase64Secret(ctx context.Context, ref string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+func resolveEnvSecret(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
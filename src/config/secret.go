@@ -1,23 +1,110 @@
 package config
 
-import "github.com/spf13/viper"
-
-func ViperBind() {
-	viper.BindEnv("ENV")
-
-	// Binding Swagger Auth
-	viper.BindEnv("SWAGGER_USERNAME")
-	viper.BindEnv("SWAGGER_PASSWORD")
-
-	// Binding Database
-	viper.BindEnv("DB_HOST")
-	viper.BindEnv("DB_USER")
-	viper.BindEnv("DB_PASSWORD")
-	viper.BindEnv("DB_NAME")
-	viper.BindEnv("DB_PORT")
-	viper.BindEnv("SSL_MODE")
-	viper.BindEnv("TZ")
-
-	// Binding GCP Cred
-	viper.BindEnv("GOOGLE_APPLICATION_CREDENTIALS_BASE64")
+import (
+	"github.com/spf13/viper"
+	"reflect"
+	"strings"
+)
+
+const (
+	tagMapstructure = "mapstructure"
+	tagAlias        = "alias"
+	tagEnv          = "env"
+	tagDefault      = "default"
+)
+
+type (
+	// configField is a leaf field of Config, Key is its nested key (e.g. host.tls.cert_file).
+	// Env is its environment variable, Envs the variables read (Env first, then its former names),
+	// Aliases the former nested keys still read (e.g. datasource.postgres.host).
+	configField struct {
+		Key     string
+		Env     string
+		Envs    []string
+		Aliases []string
+		Field   reflect.StructField
+	}
+)
+
+// ViperBind binds every key of Config to the environment variable declared in its env tag,
+// and sets the default declared in its default tag,
+// so the environment variables always match the struct they are unmarshalled into
+func ViperBind(v *viper.Viper) {
+	for _, field := range configFields() {
+		if field.Env != "" {
+			v.BindEnv(append([]string{field.Key}, field.Envs...)...)
+		}
+		if defaultValue, ok := field.Field.Tag.Lookup(tagDefault); ok {
+			v.SetDefault(field.Key, defaultValue)
+		}
+	}
+}
+
+// ConfigKeys returns the nested key of every leaf field of Config
+func ConfigKeys() []string {
+	fields := configFields()
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		keys = append(keys, field.Key)
+	}
+	return keys
+}
+
+func configFields() []configField {
+	var fields []configField
+	walkConfigFields(reflect.TypeOf(Config{}), "", nil, func(key string, aliases []string, field reflect.StructField) {
+		var envs []string
+		if env := field.Tag.Get(tagEnv); env != "" {
+			envs = strings.Split(env, ",")
+		}
+		fields = append(fields, configField{
+			Key:     key,
+			Env:     strings.Split(field.Tag.Get(tagEnv), ",")[0],
+			Envs:    envs,
+			Aliases: aliases,
+			Field:   field,
+		})
+	})
+	return fields
+}
+
+// walkConfigFields calls fn for every leaf field of t with its former keys (see the alias tag),
+// descending into nested structs
+func walkConfigFields(t reflect.Type, prefix string, prefixAliases []string, fn func(key string, aliases []string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(tagMapstructure), ",")[0]
+		if name == "" {
+			continue
+		}
+
+		names := []string{name}
+		if alias := field.Tag.Get(tagAlias); alias != "" {
+			names = append(names, alias)
+		}
+		key := joinKey(prefix, name)
+		var aliases []string
+		for _, prefixAlias := range prefixAliases {
+			for _, name := range names {
+				aliases = append(aliases, joinKey(prefixAlias, name))
+			}
+		}
+		for _, name := range names[1:] {
+			aliases = append(aliases, joinKey(prefix, name))
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			walkConfigFields(field.Type, key, aliases, fn)
+			continue
+		}
+
+		fn(key, aliases, field)
+	}
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
 }
@@ -0,0 +1,32 @@
+// Package databasetest opens the datasources the tests run against.
+package databasetest
+
+import (
+	"context"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/database"
+	"path/filepath"
+	"testing"
+)
+
+// OpenSqlite opens an empty sqlite DBCollection in the test temp dir, with both the sqlx and the gorm handles,
+// closed at the end of the test
+func OpenSqlite(t testing.TB) database.DBCollection {
+	t.Helper()
+	cfg := config.Config{}
+	cfg.DataSource.SqlDBConfig = config.SqlDBConfig{
+		Enabled:     true,
+		SqlxEnabled: true,
+		GormEnabled: true,
+		Driver:      "sqlite",
+		Name:        filepath.Join(t.TempDir(), "test.db"),
+	}
+	cfg.DataSource.Retry.MaxAttempts = 1
+
+	db, err := database.NewDatabaseCollection(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(context.Background()) })
+	return db
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+	"go-chi-boilerplate/src/health"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
+)
+
+// RegisterHealthChecks registers a readiness check for every datasource in the collection
+func (d DBCollection) RegisterHealthChecks(registry health.Registry, timeout time.Duration) {
+	if d.MongoDB != nil {
+		registry.Register("mongodb", timeout, func(ctx context.Context) error {
+			return d.MongoDB.Client().Ping(ctx, readpref.Primary())
+		})
+	}
+
+	if d.SqlDB != nil {
+		registry.Register(d.sqlDriver, timeout, d.SqlDB.PingContext)
+	}
+}
@@ -0,0 +1,507 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/migrations"
+	"go-chi-boilerplate/src/tools"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DIRECTION_UP   = "up"
+	DIRECTION_DOWN = "down"
+
+	// NO_TRANSACTION as the first line of a file runs it outside of a transaction (e.g. CREATE INDEX CONCURRENTLY)
+	NO_TRANSACTION = "-- migrate:no-transaction"
+
+	versionLayout = "20060102150405"
+)
+
+// the files suffixed with a dialect (e.g. 20240101000000_create_examples.up.sqlite.sql) replace the unsuffixed ones for it
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)(?:\.(postgres|mysql|sqlite))?\.sql$`)
+
+type (
+	// Migration is a version of the schema, read from <version>_<name>.up.sql and <version>_<name>.down.sql
+	Migration struct {
+		Version  int64
+		Name     string
+		UpFile   string
+		DownFile string
+	}
+
+	// Status of a migration, AppliedAt is nil while it is pending
+	Status struct {
+		Version   int64      `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at"`
+	}
+
+	// Migrator applies the migrations of its source and tracks the applied versions in its table.
+	// Every command holds a postgres advisory lock (a named lock with mysql), so concurrent pods never run the same
+	// migration twice. sqlite has no such lock, its database being local to a single process.
+	// mysql commits the schema changes as they run, so a failing file may be left half applied.
+	Migrator interface {
+		// Up applies the next steps pending migrations, every one of them when steps is 0
+		Up(ctx context.Context, steps int) error
+		// Down reverts the last steps applied migrations, every one of them when steps is 0
+		Down(ctx context.Context, steps int) error
+		// Goto applies or reverts migrations until version is the last one applied, 0 reverts everything
+		Goto(ctx context.Context, version int64) error
+		Status(ctx context.Context) ([]Status, error)
+	}
+
+	MigratorImpl struct {
+		db      *sql.DB
+		dialect string
+		source  fs.FS
+		table   string
+	}
+
+	execer interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	}
+)
+
+// NewMigrator builds a migrator of db, driver (postgres, pgx, mysql or sqlite) picks the SQL of its own statements
+// and the dialect variant of the files
+func NewMigrator(db *sql.DB, driver string, source fs.FS, table string) Migrator {
+	return &MigratorImpl{
+		db:      db,
+		dialect: tools.SqlDialect(driver),
+		source:  source,
+		table:   table,
+	}
+}
+
+func (m *MigratorImpl) Up(ctx context.Context, steps int) error {
+	return m.run(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]time.Time) error {
+		var pending []Migration
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				pending = append(pending, migration)
+			}
+		}
+		if steps > 0 && steps < len(pending) {
+			pending = pending[:steps]
+		}
+
+		if len(pending) == 0 {
+			logrus.Println("[INFO] no pending migration")
+		}
+		for _, migration := range pending {
+			if err := m.apply(ctx, conn, migration, DIRECTION_UP); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MigratorImpl) Down(ctx context.Context, steps int) error {
+	return m.run(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]time.Time) error {
+		reverting, err := appliedMigrations(migrations, applied)
+		if err != nil {
+			return err
+		}
+		if steps > 0 && steps < len(reverting) {
+			reverting = reverting[:steps]
+		}
+
+		if len(reverting) == 0 {
+			logrus.Println("[INFO] no applied migration")
+		}
+		for _, migration := range reverting {
+			if err := m.apply(ctx, conn, migration, DIRECTION_DOWN); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MigratorImpl) Goto(ctx context.Context, version int64) error {
+	return m.run(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]time.Time) error {
+		if version != 0 && !containsVersion(migrations, version) {
+			return fmt.Errorf("migration %d not found", version)
+		}
+
+		reverting, err := appliedMigrations(migrations, applied)
+		if err != nil {
+			return err
+		}
+		for _, migration := range reverting {
+			if migration.Version <= version {
+				break
+			}
+			if err := m.apply(ctx, conn, migration, DIRECTION_DOWN); err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, DIRECTION_UP); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MigratorImpl) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.run(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]time.Time) error {
+		names := make(map[int64]string, len(migrations))
+		for _, migration := range migrations {
+			names[migration.Version] = migration.Name
+			statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name})
+		}
+
+		// applied versions whose files were removed
+		for version := range applied {
+			if _, ok := names[version]; !ok {
+				statuses = append(statuses, Status{Version: version, Name: "<missing file>"})
+			}
+		}
+
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		for i := range statuses {
+			if appliedAt, ok := applied[statuses[i].Version]; ok {
+				statuses[i].AppliedAt = &appliedAt
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// run holds the migration lock on a dedicated connection while fn migrates the schema
+func (m *MigratorImpl) run(ctx context.Context, fn func(conn *sql.Conn, migrations []Migration, applied map[int64]time.Time) error) error {
+	migrations, err := Load(m.source, m.dialect)
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error when acquiring migration connection, error: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.lock(ctx, conn); err != nil {
+		return fmt.Errorf("error when acquiring migration lock, error: %w", err)
+	}
+	defer func() {
+		if err := m.unlock(conn); err != nil {
+			logrus.Errorf("error when releasing migration lock, error: %v", err)
+		}
+	}()
+
+	createTable := fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW())",
+		m.quotedTable(),
+	)
+	switch m.dialect {
+	case tools.DIALECT_MYSQL:
+		createTable = fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6))",
+			m.quotedTable(),
+		)
+	case tools.DIALECT_SQLITE:
+		createTable = fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+			m.quotedTable(),
+		)
+	}
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("error when creating %s, error: %w", m.table, err)
+	}
+
+	applied, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+// lock takes the lock of the migration table, bound to the connection so it is released if the process dies
+func (m *MigratorImpl) lock(ctx context.Context, conn *sql.Conn) error {
+	switch m.dialect {
+	case tools.DIALECT_MYSQL:
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", m.table).Scan(&locked); err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return fmt.Errorf("lock %s not granted", m.table)
+		}
+		return nil
+	case tools.DIALECT_SQLITE:
+		return nil
+	}
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", m.table)
+	return err
+}
+
+func (m *MigratorImpl) unlock(conn *sql.Conn) error {
+	var err error
+	switch m.dialect {
+	case tools.DIALECT_MYSQL:
+		_, err = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.table)
+	case tools.DIALECT_POSTGRES:
+		_, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", m.table)
+	}
+	return err
+}
+
+func (m *MigratorImpl) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", m.quotedTable()))
+	if err != nil {
+		return nil, fmt.Errorf("error when reading %s, error: %w", m.table, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error when reading %s, error: %w", m.table, err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// apply runs the file of direction and records it, both in one transaction unless the file opts out
+func (m *MigratorImpl) apply(ctx context.Context, conn *sql.Conn, migration Migration, direction string) error {
+	var (
+		file   = migration.UpFile
+		record = fmt.Sprintf("INSERT INTO %s (version, name) VALUES (%s, %s)", m.quotedTable(), m.placeholder(1), m.placeholder(2))
+		args   = []interface{}{migration.Version, migration.Name}
+	)
+	if direction == DIRECTION_DOWN {
+		if migration.DownFile == "" {
+			return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+		file = migration.DownFile
+		record = fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.quotedTable(), m.placeholder(1))
+		args = args[:1]
+	}
+
+	content, err := fs.ReadFile(m.source, file)
+	if err != nil {
+		return fmt.Errorf("error when reading %s, error: %w", file, err)
+	}
+	query := string(content)
+
+	logrus.Printf("[INFO] migrating %s %d_%s", direction, migration.Version, migration.Name)
+	start := time.Now()
+
+	if strings.HasPrefix(strings.TrimSpace(query), NO_TRANSACTION) {
+		if err := m.exec(ctx, conn, query); err != nil {
+			return fmt.Errorf("error when running %s, error: %w", file, err)
+		}
+		if _, err := conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("error when recording %s, error: %w", file, err)
+		}
+	} else {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error when beginning transaction for %s, error: %w", file, err)
+		}
+		if err := m.exec(ctx, tx, query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error when running %s, error: %w", file, err)
+		}
+		if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error when recording %s, error: %w", file, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error when committing %s, error: %w", file, err)
+		}
+	}
+
+	logrus.Printf("[INFO] migrated %s %d_%s in %s", direction, migration.Version, migration.Name, time.Since(start))
+	return nil
+}
+
+// exec runs the statements of a file, one by one with mysql which runs a single statement per call
+func (m *MigratorImpl) exec(ctx context.Context, db execer, query string) error {
+	if m.dialect != tools.DIALECT_MYSQL {
+		_, err := db.ExecContext(ctx, query)
+		return err
+	}
+
+	for _, statement := range splitStatements(query) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MigratorImpl) placeholder(n int) string {
+	if m.dialect == tools.DIALECT_POSTGRES {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func (m *MigratorImpl) quotedTable() string {
+	return tools.QuoteSqlIdentifier(m.dialect, m.table)
+}
+
+// splitStatements splits query after the semicolons ending a line, leaving out the parts holding only comments
+func splitStatements(query string) []string {
+	var (
+		statements []string
+		statement  strings.Builder
+		code       bool
+	)
+	for _, line := range strings.SplitAfter(query, "\n") {
+		statement.WriteString(line)
+		trimmed := strings.TrimSpace(line)
+		code = code || (trimmed != "" && !strings.HasPrefix(trimmed, "--"))
+
+		if strings.HasSuffix(trimmed, ";") && code {
+			statements = append(statements, statement.String())
+			statement.Reset()
+			code = false
+		}
+	}
+	if code {
+		statements = append(statements, statement.String())
+	}
+	return statements
+}
+
+// Load reads the migrations of source for dialect, sorted by version. The files suffixed with dialect replace the
+// unsuffixed ones, the files of the other dialects are left out.
+func Load(source fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error when reading migrations, error: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil || (matches[4] != "" && matches[4] != dialect) {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, matches[2])
+		}
+
+		file := &migration.UpFile
+		if matches[3] == DIRECTION_DOWN {
+			file = &migration.DownFile
+		}
+		if *file == "" || matches[4] != "" {
+			*file = entry.Name()
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpFile == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Create writes an empty up and down file for name in dir, versioned by the current UTC time
+func Create(dir, name string) (upFile, downFile string, err error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", fmt.Errorf("error when creating %s, error: %w", dir, err)
+	}
+
+	version := time.Now().UTC().Format(versionLayout)
+	for _, direction := range []string{DIRECTION_UP, DIRECTION_DOWN} {
+		file := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s %s\n", name, direction)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return "", "", fmt.Errorf("error when writing %s, error: %w", file, err)
+		}
+
+		if direction == DIRECTION_UP {
+			upFile = file
+		} else {
+			downFile = file
+		}
+	}
+
+	return upFile, downFile, nil
+}
+
+// appliedMigrations returns the applied migrations, last applied first
+func appliedMigrations(migrations []Migration, applied map[int64]time.Time) ([]Migration, error) {
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	result := make([]Migration, 0, len(applied))
+	for version := range applied {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %d has no file", version)
+		}
+		result = append(result, migration)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version > result[j].Version })
+
+	return result, nil
+}
+
+func containsVersion(migrations []Migration, version int64) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Source returns the migrations of dir, or the ones embedded in the binary when dir is empty
+func Source(dir string) fs.FS {
+	if dir == "" {
+		return migrations.FS
+	}
+	return os.DirFS(dir)
+}
@@ -0,0 +1,226 @@
+package migration
+
+import (
+	"context"
+	"go-chi-boilerplate/src/database/databasetest"
+	"go-chi-boilerplate/src/tools"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		dialect string
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name:    "sorted by version",
+			files:   []string{"2_b.up.sql", "1_a.up.sql", "1_a.down.sql", "notes.md"},
+			dialect: tools.DIALECT_POSTGRES,
+			want: []Migration{
+				{Version: 1, Name: "a", UpFile: "1_a.up.sql", DownFile: "1_a.down.sql"},
+				{Version: 2, Name: "b", UpFile: "2_b.up.sql"},
+			},
+		},
+		{
+			name:    "dialect file replaces the unsuffixed one",
+			files:   []string{"1_a.up.sqlite.sql", "1_a.up.sql", "1_a.down.sql", "1_a.down.mysql.sql"},
+			dialect: tools.DIALECT_SQLITE,
+			want:    []Migration{{Version: 1, Name: "a", UpFile: "1_a.up.sqlite.sql", DownFile: "1_a.down.sql"}},
+		},
+		{
+			name:    "files of the other dialects left out",
+			files:   []string{"1_a.up.sql", "2_b.up.mysql.sql"},
+			dialect: tools.DIALECT_POSTGRES,
+			want:    []Migration{{Version: 1, Name: "a", UpFile: "1_a.up.sql"}},
+		},
+		{
+			name:    "two names",
+			files:   []string{"1_a.up.sql", "1_b.down.sql"},
+			wantErr: "migration 1 has two names",
+		},
+		{
+			name:    "no up file",
+			files:   []string{"1_a.down.sql"},
+			wantErr: "migration 1_a has no up file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := fstest.MapFS{}
+			for _, file := range tt.files {
+				source[file] = &fstest.MapFile{}
+			}
+
+			got, err := Load(source, tt.dialect)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "one per line",
+			query: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:  []string{"CREATE TABLE a (id INT);\n", "CREATE TABLE b (id INT);\n"},
+		},
+		{
+			name:  "statement over several lines",
+			query: "CREATE TABLE a (\n  id INT\n);\n",
+			want:  []string{"CREATE TABLE a (\n  id INT\n);\n"},
+		},
+		{
+			name:  "semicolon inside a line",
+			query: "INSERT INTO a VALUES ('x;y');\n",
+			want:  []string{"INSERT INTO a VALUES ('x;y');\n"},
+		},
+		{
+			name:  "comments only part left out",
+			query: "-- header\nCREATE TABLE a (id INT);\n-- footer;\n",
+			want:  []string{"-- header\nCREATE TABLE a (id INT);\n"},
+		},
+		{
+			name:  "last statement without semicolon",
+			query: "CREATE TABLE a (id INT);\nDROP TABLE b",
+			want:  []string{"CREATE TABLE a (id INT);\n", "DROP TABLE b"},
+		},
+		{
+			name:  "empty",
+			query: "\n-- nothing\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// the advisory lock of postgres and the named lock of mysql need their server, sqlite runs the rest of the migrator
+func TestMigratorSqlite(t *testing.T) {
+	source := fstest.MapFS{
+		"1_a.up.sql":       {Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);\n")},
+		"1_a.down.sql":     {Data: []byte("DROP TABLE a;\n")},
+		"2_b.up.sql":       {Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY);\nINSERT INTO b (id) VALUES (1);\n")},
+		"2_b.down.sql":     {Data: []byte("DROP TABLE b;\n")},
+		"3_fails.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER PRIMARY KEY);\nINSERT INTO missing (id) VALUES (1);\n")},
+		"3_fails.down.sql": {Data: []byte("DROP TABLE c;\n")},
+	}
+
+	tests := []struct {
+		name        string
+		run         func(ctx context.Context, m Migrator) error
+		wantErr     string
+		wantApplied []int64
+		wantTables  []string
+	}{
+		{
+			name:        "up steps",
+			run:         func(ctx context.Context, m Migrator) error { return m.Up(ctx, 2) },
+			wantApplied: []int64{1, 2},
+			wantTables:  []string{"a", "b"},
+		},
+		{
+			name:        "failing file rolled back",
+			run:         func(ctx context.Context, m Migrator) error { return m.Up(ctx, 0) },
+			wantErr:     "error when running 3_fails.up.sql",
+			wantApplied: []int64{1, 2},
+			wantTables:  []string{"a", "b"},
+		},
+		{
+			name: "down",
+			run: func(ctx context.Context, m Migrator) error {
+				if err := m.Up(ctx, 2); err != nil {
+					return err
+				}
+				return m.Down(ctx, 1)
+			},
+			wantApplied: []int64{1},
+			wantTables:  []string{"a"},
+		},
+		{
+			name: "goto",
+			run: func(ctx context.Context, m Migrator) error {
+				if err := m.Goto(ctx, 2); err != nil {
+					return err
+				}
+				return m.Goto(ctx, 0)
+			},
+		},
+		{
+			name:    "goto unknown version",
+			run:     func(ctx context.Context, m Migrator) error { return m.Goto(ctx, 9) },
+			wantErr: "migration 9 not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := databasetest.OpenSqlite(t).SqlDB
+			m := NewMigrator(db, tools.DIALECT_SQLITE, source, "schema_migrations")
+
+			err := tt.run(ctx, m)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var applied []int64
+			for _, status := range statuses {
+				if status.AppliedAt != nil {
+					applied = append(applied, status.Version)
+				}
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+
+			var tables []string
+			rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var table string
+				if err := rows.Scan(&table); err != nil {
+					t.Fatal(err)
+				}
+				tables = append(tables, table)
+			}
+			if !reflect.DeepEqual(tables, tt.wantTables) {
+				t.Errorf("tables = %v, want %v", tables, tt.wantTables)
+			}
+		})
+	}
+}
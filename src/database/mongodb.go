@@ -2,29 +2,63 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/config"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
 )
 
-func InitializeMongoDatabase(ctx context.Context, dsn, dbName string) *mongo.Database {
+// InitializeMongoDatabase connects to cfg, poolMonitor and commandMonitor may be nil
+func InitializeMongoDatabase(ctx context.Context, cfg config.MongoDBConfig, poolMonitor *event.PoolMonitor, commandMonitor *event.CommandMonitor) (*mongo.Database, error) {
 	log := logrus.WithContext(ctx)
 
-	clientOptions := options.Client().ApplyURI(dsn)
+	clientOptions := mongoClientOptions(cfg).SetPoolMonitor(poolMonitor).SetMonitor(commandMonitor)
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		log.Fatalf("error when mongo.Connect(ctx, clientOptions), request: %v, error: %v", dsn, err.Error())
-		return nil
+		return nil, fmt.Errorf("error when mongo.Connect(ctx, clientOptions), error: %w", err)
 	}
 
-	mongoDB := client.Database(dbName)
+	mongoDB := client.Database(cfg.DatabaseName)
 
-	log.Infof("ping mongodb %s", dbName)
-	if err := mongoDB.Client().Ping(context.Background(), readpref.Primary()); err != nil {
-		log.Fatalf("error when ping mongodb %s, error: %v", dbName, err.Error())
+	log.Infof("ping mongodb %s", cfg.DatabaseName)
+	if err := mongoDB.Client().Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("error when ping mongodb %s, error: %w", cfg.DatabaseName, err)
 	}
 
-	return mongoDB
+	return mongoDB, nil
+}
+
+// mongoClientOptions applies the pool, timeout and compression settings, leaving the driver defaults for zero values
+func mongoClientOptions(cfg config.MongoDBConfig) *options.ClientOptions {
+	clientOptions := options.Client().ApplyURI(cfg.ConnectionString)
+
+	if cfg.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(uint64(cfg.MaxPoolSize))
+	}
+	if cfg.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(uint64(cfg.MinPoolSize))
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(time.Second * time.Duration(cfg.MaxConnIdleTime))
+	}
+	if cfg.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(time.Second * time.Duration(cfg.ConnectTimeout))
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(time.Second * time.Duration(cfg.ServerSelectionTimeout))
+	}
+	if cfg.Timeout > 0 {
+		clientOptions.SetTimeout(time.Second * time.Duration(cfg.Timeout))
+	}
+	if len(cfg.Compressors) > 0 {
+		clientOptions.SetCompressors(cfg.Compressors)
+	}
+
+	return clientOptions
 }
@@ -0,0 +1,382 @@
+package mongoschema
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Actions reported by Reconcile
+const (
+	ACTION_CREATE_COLLECTION = "create_collection"
+	ACTION_UPDATE_VALIDATOR  = "update_validator"
+	ACTION_CREATE_INDEX      = "create_index"
+	ACTION_DROP_INDEX        = "drop_index"
+	// ACTION_DRIFT is a difference that cannot be fixed without data loss, it is only reported
+	ACTION_DRIFT = "drift"
+	// ACTION_UNDECLARED_INDEX is an index not declared in code, dropped only when DropUndeclared is set
+	ACTION_UNDECLARED_INDEX = "undeclared_index"
+
+	idIndexName = "_id_"
+)
+
+type (
+	// IndexSpec declares an index, Name defaults to the one generated by MongoDB (e.g. name_1_created_at_-1)
+	IndexSpec struct {
+		Name          string
+		Keys          bson.D
+		Unique        bool
+		Sparse        bool
+		ExpireAfter   time.Duration // TTL index when positive, Keys must be a single date field
+		PartialFilter bson.M
+	}
+
+	// CollectionSpec declares a collection, its JSON-schema validator and its indexes.
+	//
+	//	Usage example:
+	//		var exampleCollection = mongoschema.CollectionSpec{
+	//			Name:      "examples",
+	//			Validator: bson.M{"$jsonSchema": bson.M{"bsonType": "object", "required": bson.A{"name"}}},
+	//			Indexes: []mongoschema.IndexSpec{
+	//				{Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+	//				{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfter: time.Second},
+	//			},
+	//		}
+	CollectionSpec struct {
+		Name      string
+		Validator bson.M
+		// Capped collections are created with SizeInBytes and MaxDocuments, they cannot be changed afterward
+		Capped       bool
+		SizeInBytes  int64
+		MaxDocuments int64
+		Indexes      []IndexSpec
+	}
+
+	Options struct {
+		// DryRun only reports the changes
+		DryRun bool
+		// DropUndeclared drops the indexes that are not declared in code
+		DropUndeclared bool
+	}
+
+	Change struct {
+		Collection string `json:"collection"`
+		Index      string `json:"index,omitempty"`
+		Action     string `json:"action"`
+		Detail     string `json:"detail,omitempty"`
+		Applied    bool   `json:"applied"`
+	}
+
+	existingCollection struct {
+		Validator bson.M `bson:"validator"`
+		Capped    bool   `bson:"capped"`
+		Size      int64  `bson:"size"`
+		Max       int64  `bson:"max"`
+	}
+
+	existingIndex struct {
+		Name                    string `bson:"name"`
+		Key                     bson.D `bson:"key"`
+		Unique                  bool   `bson:"unique"`
+		Sparse                  bool   `bson:"sparse"`
+		ExpireAfterSeconds      *int64 `bson:"expireAfterSeconds"`
+		PartialFilterExpression bson.M `bson:"partialFilterExpression"`
+	}
+)
+
+// IndexName returns the declared name, or the one MongoDB generates from the keys
+func (i IndexSpec) IndexName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+
+	parts := make([]string, 0, len(i.Keys)*2)
+	for _, key := range i.Keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// Reconcile creates the missing collections and indexes, updates the changed validators
+// and reports the drift between the specs and the database
+func Reconcile(ctx context.Context, db *mongo.Database, specs []CollectionSpec, opts Options) ([]Change, error) {
+	var changes []Change
+	for _, spec := range specs {
+		collectionChanges, err := reconcileCollection(ctx, db, spec, opts)
+		changes = append(changes, collectionChanges...)
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+func reconcileCollection(ctx context.Context, db *mongo.Database, spec CollectionSpec, opts Options) ([]Change, error) {
+	var changes []Change
+
+	existing, found, err := findCollection(ctx, db, spec.Name)
+	if err != nil {
+		return changes, err
+	}
+
+	if !found {
+		change := Change{Collection: spec.Name, Action: ACTION_CREATE_COLLECTION, Applied: !opts.DryRun}
+		if !opts.DryRun {
+			if err := db.CreateCollection(ctx, spec.Name, createCollectionOptions(spec)); err != nil {
+				return changes, fmt.Errorf("error when creating collection %s, error: %w", spec.Name, err)
+			}
+		}
+		changes = append(changes, change)
+	} else {
+		if existing.Capped != spec.Capped {
+			changes = append(changes, Change{
+				Collection: spec.Name,
+				Action:     ACTION_DRIFT,
+				Detail:     fmt.Sprintf("capped is %t, declared %t", existing.Capped, spec.Capped),
+			})
+		}
+
+		if !sameDocument(existing.Validator, spec.Validator) {
+			change := Change{Collection: spec.Name, Action: ACTION_UPDATE_VALIDATOR, Applied: !opts.DryRun}
+			if !opts.DryRun {
+				validator := spec.Validator
+				if validator == nil {
+					validator = bson.M{}
+				}
+				command := bson.D{{Key: "collMod", Value: spec.Name}, {Key: "validator", Value: validator}}
+				if err := db.RunCommand(ctx, command).Err(); err != nil {
+					return changes, fmt.Errorf("error when updating validator of %s, error: %w", spec.Name, err)
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	indexChanges, err := reconcileIndexes(ctx, db.Collection(spec.Name), spec, opts, found)
+	changes = append(changes, indexChanges...)
+	return changes, err
+}
+
+func reconcileIndexes(ctx context.Context, collection *mongo.Collection, spec CollectionSpec, opts Options, collectionFound bool) ([]Change, error) {
+	var changes []Change
+
+	existing := make(map[string]existingIndex)
+	if collectionFound {
+		cursor, err := collection.Indexes().List(ctx)
+		if err != nil {
+			return changes, fmt.Errorf("error when listing indexes of %s, error: %w", spec.Name, err)
+		}
+		var indexes []existingIndex
+		if err := cursor.All(ctx, &indexes); err != nil {
+			return changes, fmt.Errorf("error when listing indexes of %s, error: %w", spec.Name, err)
+		}
+		for _, index := range indexes {
+			existing[index.Name] = index
+		}
+	}
+
+	declared := make(map[string]bool, len(spec.Indexes))
+	for _, index := range spec.Indexes {
+		name := index.IndexName()
+		declared[name] = true
+
+		if current, ok := existing[name]; ok {
+			if diff := indexDiff(current, index); diff != "" {
+				changes = append(changes, Change{Collection: spec.Name, Index: name, Action: ACTION_DRIFT, Detail: diff})
+			}
+			continue
+		}
+
+		change := Change{Collection: spec.Name, Index: name, Action: ACTION_CREATE_INDEX, Applied: !opts.DryRun}
+		if !opts.DryRun {
+			if _, err := collection.Indexes().CreateOne(ctx, indexModel(index)); err != nil {
+				return changes, fmt.Errorf("error when creating index %s on %s, error: %w", name, spec.Name, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	for name := range existing {
+		if name == idIndexName || declared[name] {
+			continue
+		}
+
+		if !opts.DropUndeclared {
+			changes = append(changes, Change{Collection: spec.Name, Index: name, Action: ACTION_UNDECLARED_INDEX})
+			continue
+		}
+
+		change := Change{Collection: spec.Name, Index: name, Action: ACTION_DROP_INDEX, Applied: !opts.DryRun}
+		if !opts.DryRun {
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+				return changes, fmt.Errorf("error when dropping index %s on %s, error: %w", name, spec.Name, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func findCollection(ctx context.Context, db *mongo.Database, name string) (existingCollection, bool, error) {
+	var existing existingCollection
+
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: name}})
+	if err != nil {
+		return existing, false, fmt.Errorf("error when listing collection %s, error: %w", name, err)
+	}
+	if len(specs) == 0 {
+		return existing, false, nil
+	}
+
+	if len(specs[0].Options) > 0 {
+		if err := bson.Unmarshal(specs[0].Options, &existing); err != nil {
+			return existing, true, fmt.Errorf("error when reading options of %s, error: %w", name, err)
+		}
+	}
+	return existing, true, nil
+}
+
+func createCollectionOptions(spec CollectionSpec) *options.CreateCollectionOptions {
+	opts := options.CreateCollection()
+	if spec.Validator != nil {
+		opts.SetValidator(spec.Validator)
+	}
+	if spec.Capped {
+		opts.SetCapped(true).SetSizeInBytes(spec.SizeInBytes)
+		if spec.MaxDocuments > 0 {
+			opts.SetMaxDocuments(spec.MaxDocuments)
+		}
+	}
+	return opts
+}
+
+func indexModel(index IndexSpec) mongo.IndexModel {
+	opts := options.Index().SetName(index.IndexName())
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+	if index.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+	if index.PartialFilter != nil {
+		opts.SetPartialFilterExpression(index.PartialFilter)
+	}
+
+	return mongo.IndexModel{Keys: index.Keys, Options: opts}
+}
+
+// indexDiff describes how current differs from the declared index, MongoDB cannot alter an index in place
+func indexDiff(current existingIndex, index IndexSpec) string {
+	var diffs []string
+
+	if !sameKeys(current.Key, index.Keys) {
+		diffs = append(diffs, fmt.Sprintf("keys are %v, declared %v", current.Key, index.Keys))
+	}
+	if current.Unique != index.Unique {
+		diffs = append(diffs, fmt.Sprintf("unique is %t, declared %t", current.Unique, index.Unique))
+	}
+	if current.Sparse != index.Sparse {
+		diffs = append(diffs, fmt.Sprintf("sparse is %t, declared %t", current.Sparse, index.Sparse))
+	}
+
+	var currentTTL int64
+	if current.ExpireAfterSeconds != nil {
+		currentTTL = *current.ExpireAfterSeconds
+	}
+	if declaredTTL := int64(index.ExpireAfter / time.Second); currentTTL != declaredTTL {
+		diffs = append(diffs, fmt.Sprintf("expireAfterSeconds is %d, declared %d", currentTTL, declaredTTL))
+	}
+
+	if !sameDocument(current.PartialFilterExpression, index.PartialFilter) {
+		diffs = append(diffs, fmt.Sprintf("partialFilterExpression is %v, declared %v", current.PartialFilterExpression, index.PartialFilter))
+	}
+
+	return strings.Join(diffs, ", ")
+}
+
+// sameDocument compares two documents as stored by MongoDB, ignoring the field order and the numeric types (1 == int32(1) == 1.0)
+func sameDocument(a, b bson.M) bool {
+	var normalizedA, normalizedB bson.M
+	return roundTrip(a, &normalizedA) == nil && roundTrip(b, &normalizedB) == nil &&
+		reflect.DeepEqual(normalizeValue(normalizedA), normalizeValue(normalizedB))
+}
+
+// sameKeys compares two index keys, in order, ignoring the numeric types
+func sameKeys(a, b bson.D) bool {
+	var normalizedA, normalizedB bson.D
+	return roundTrip(a, &normalizedA) == nil && roundTrip(b, &normalizedB) == nil &&
+		reflect.DeepEqual(normalizeValue(normalizedA), normalizeValue(normalizedB))
+}
+
+// roundTrip decodes doc into result, so both sides of a comparison use the types decoded from BSON
+func roundTrip(doc interface{}, result interface{}) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		if len(v) == 0 {
+			return nil
+		}
+		result := make(bson.M, len(v))
+		for key, e := range v {
+			result[key] = normalizeValue(e)
+		}
+		return result
+	case bson.D:
+		if len(v) == 0 {
+			return nil
+		}
+		result := make(bson.D, len(v))
+		for i, e := range v {
+			result[i] = bson.E{Key: e.Key, Value: normalizeValue(e.Value)}
+		}
+		return result
+	case bson.A:
+		result := make(bson.A, len(v))
+		for i, e := range v {
+			result[i] = normalizeValue(e)
+		}
+		return result
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+// LogChanges logs every change, the drifts and undeclared indexes as warnings
+func LogChanges(changes []Change) {
+	for _, change := range changes {
+		target := change.Collection
+		if change.Index != "" {
+			target = fmt.Sprintf("%s.%s", change.Collection, change.Index)
+		}
+
+		switch {
+		case change.Action == ACTION_DRIFT || change.Action == ACTION_UNDECLARED_INDEX:
+			logrus.Warnf("mongodb schema %s on %s %s", change.Action, target, change.Detail)
+		case change.Applied:
+			logrus.Printf("[INFO] mongodb schema %s on %s", change.Action, target)
+		default:
+			logrus.Printf("[INFO] mongodb schema %s on %s (dry run)", change.Action, target)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"sync"
+	"time"
+)
+
+type (
+	// monitoredDatasource is pinged by the Monitor, reset discards the connections that may be broken
+	// and setHealthy (optional) is told the result of every ping
+	monitoredDatasource struct {
+		name       string
+		ping       func(ctx context.Context) error
+		reset      func()
+		setHealthy func(healthy bool)
+	}
+
+	// Monitor pings every datasource on a schedule once the service is started.
+	// A failing datasource is reported unhealthy (and so is /readyz) and re-established with backoff instead of stopping the service:
+	// its broken connections are discarded and the drivers dial new ones until a ping succeeds.
+	Monitor struct {
+		datasources []monitoredDatasource
+		interval    time.Duration
+		timeout     time.Duration
+		backoff     tools.Backoff
+
+		stop chan struct{}
+		wg   sync.WaitGroup
+	}
+)
+
+// NewMonitor builds a monitor of every datasource in the collection, pinged every cfg.DataSource.MonitorInterval
+// and bounded by cfg.HealthCheckTimeout. The re-establishing attempts use the retry backoff, without attempts limit.
+func (d DBCollection) NewMonitor(cfg config.Config) *Monitor {
+	backoff := retryBackoff(cfg.DataSource.Retry)
+	backoff.MaxAttempts = 0
+
+	m := &Monitor{
+		interval: time.Second * time.Duration(cfg.DataSource.MonitorInterval),
+		timeout:  time.Second * time.Duration(cfg.HealthCheckTimeout),
+		backoff:  backoff,
+		stop:     make(chan struct{}),
+	}
+
+	if d.MongoDB != nil {
+		m.datasources = append(m.datasources, monitoredDatasource{
+			name: "mongodb",
+			ping: func(ctx context.Context) error {
+				return d.MongoDB.Client().Ping(ctx, readpref.Primary())
+			},
+			// the driver monitors the topology and reconnects on its own
+			reset: func() {},
+		})
+	}
+
+	if d.SqlDB != nil {
+		m.datasources = append(m.datasources, monitoredDatasource{
+			name:  d.sqlDriver,
+			ping:  d.SqlDB.PingContext,
+			reset: func() { resetSqlPool(d.SqlDB, d.sqlPool) },
+		})
+	}
+
+	// replicas are taken out of rotation while they fail, the reads going to the other ones or to the primary
+	if d.replicas != nil {
+		for _, r := range d.replicas.replicas {
+			r := r
+			m.datasources = append(m.datasources, monitoredDatasource{
+				name:       r.driver + " replica " + r.host,
+				ping:       r.db.PingContext,
+				reset:      func() { resetSqlPool(r.db, d.sqlPool) },
+				setHealthy: r.setHealthy,
+			})
+		}
+	}
+
+	return m
+}
+
+// resetSqlPool closes the idle connections of db, the next queries dial new ones
+func resetSqlPool(db *sql.DB, pool tools.SqlPoolConfig) {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+}
+
+// Start monitors every datasource until Stop is called, it does nothing when the interval is not positive
+func (m *Monitor) Start() {
+	if m.interval <= 0 {
+		return
+	}
+
+	for _, ds := range m.datasources {
+		m.wg.Add(1)
+		go func(ds monitoredDatasource) {
+			defer m.wg.Done()
+			m.watch(ds)
+		}(ds)
+	}
+}
+
+func (m *Monitor) watch(ds monitoredDatasource) {
+	var failures int
+	for {
+		wait := m.interval
+		if failures > 0 {
+			wait = m.backoff.Delay(failures)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-m.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		err := ds.ping(ctx)
+		cancel()
+
+		if ds.setHealthy != nil {
+			ds.setHealthy(err == nil)
+		}
+
+		if err == nil {
+			if failures > 0 {
+				logrus.Printf("[INFO] datasource %s re-established after %d failed attempt(s)", ds.name, failures)
+			}
+			failures = 0
+			continue
+		}
+
+		failures++
+		if failures == 1 {
+			logrus.Errorf("datasource %s is unhealthy, re-establishing, error: %v", ds.name, err)
+		} else {
+			logrus.Warnf("attempt %d to re-establish %s failed, error: %v", failures, ds.name, err)
+		}
+		ds.reset()
+	}
+}
+
+// Stop stops the monitoring, it is meant to be registered as a shutdown hook
+func (m *Monitor) Stop(ctx context.Context) error {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,43 +2,136 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
 	"go.mongodb.org/mongo-driver/mongo"
 	"gorm.io/gorm"
+	"time"
 )
 
 type (
+	// DBCollection holds the datasources, SqlDBSqlx and SqlDBGorm share the SqlDB pool.
+	// Repositories use Sqlx and Gorm for the primary, SqlxRead and GormRead for the reads that may go to a replica.
+	// SqlDBSqlx and SqlDBGorm are nil when disabled, Sqlx and Gorm still serve both APIs over the pool.
 	DBCollection struct {
-		MongoDB        *mongo.Database
-		PostgresDBSqlx *sqlx.DB
-		PostgresDBGorm *gorm.DB
+		MongoDB   *mongo.Database
+		SqlDB     *sql.DB
+		SqlDBSqlx *sqlx.DB
+		SqlDBGorm *gorm.DB
+
+		// sqlx and gorm handles of the SqlDB pool, built whether SqlDBSqlx and SqlDBGorm are enabled or not
+		sqlxDB *sqlx.DB
+		gormDB *gorm.DB
+
+		mongoPool *mongoPoolMonitor
+		sqlPool   tools.SqlPoolConfig
+		sqlDriver string
+		replicas  *ReplicaResolver
+		queryLog  *tools.QueryLog
 	}
 )
 
-func NewDatabaseCollection(cfg config.Config) DBCollection {
-	ctx := context.Background()
+// NewDatabaseCollection opens every datasource enabled in cfg, the disabled ones are left nil.
+// Each datasource is retried with backoff (cfg.DataSource.Retry), so the service can start before its databases are ready.
+// When any of them still fails, the ones already opened are closed.
+func NewDatabaseCollection(ctx context.Context, cfg config.Config) (DBCollection, error) {
+	var collection DBCollection
+	backoff := retryBackoff(cfg.DataSource.Retry)
+	collection.queryLog = newQueryLog(cfg.DataSource.QueryLog)
 
 	// mongodb
 	mongoDBConfig := cfg.DataSource.MongoDBConfig
-	mongoDB := InitializeMongoDatabase(ctx, mongoDBConfig.ConnectionString, mongoDBConfig.DatabaseName)
+	if mongoDBConfig.Enabled {
+		mongoPool := newMongoPoolMonitor(mongoDBConfig.MaxPoolSize)
+		commandMonitor := newMongoCommandMonitor(collection.queryLog)
+		var mongoDB *mongo.Database
+		err := tools.Retry(ctx, "mongodb", backoff, func(ctx context.Context) (err error) {
+			mongoDB, err = InitializeMongoDatabase(ctx, mongoDBConfig, mongoPool.PoolMonitor(), commandMonitor)
+			return err
+		})
+		if err != nil {
+			return collection, err
+		}
+		collection.MongoDB = mongoDB
+		collection.mongoPool = mongoPool
+	}
+
+	// sql
+	sqlDBConfig := cfg.DataSource.SqlDBConfig
+	if !sqlDBConfig.Enabled {
+		return collection, nil
+	}
+	collection.sqlPool = sqlPoolConfig(sqlDBConfig)
+
+	dsn := sqlDBConfig.DSN()
 
-	// postgres
-	postgresDBConfig := cfg.DataSource.PostgresDBConfig
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
-		postgresDBConfig.Host, postgresDBConfig.User, postgresDBConfig.Password, postgresDBConfig.Name, postgresDBConfig.Port, postgresDBConfig.SSLMode, postgresDBConfig.Timezone,
-	)
+	// sql pool, shared by sqlx and gorm
+	collection.sqlDriver = sqlDBConfig.Driver
+	err := tools.Retry(ctx, sqlDBConfig.Driver, backoff, func(ctx context.Context) (err error) {
+		collection.SqlDB, err = InitializeSqlDatabase(ctx, sqlDBConfig.Driver, dsn, collection.sqlPool)
+		return err
+	})
+	if err != nil {
+		return collection, errors.Join(err, collection.Close(ctx))
+	}
 
-	// postgres with sqlx
-	postgresDBSqlx := InitializePostgresqlDatabaseSqlx(ctx, dsn)
-	// postgres with gorm
-	postgresDBGorm := InitializePostgresqlDatabaseGorm(ctx, dsn)
+	// sql with sqlx and gorm, the disabled ones are only left unexposed
+	collection.sqlxDB = InitializeSqlDatabaseSqlx(collection.SqlDB, sqlDBConfig.Driver)
+	collection.gormDB, err = InitializeSqlDatabaseGorm(ctx, sqlDBConfig.Driver, collection.SqlDB, tools.NewGormLogger(collection.queryLog, sqlDBConfig.Driver))
+	if err != nil {
+		return collection, errors.Join(err, collection.Close(ctx))
+	}
+	if sqlDBConfig.SqlxEnabled {
+		collection.SqlDBSqlx = collection.sqlxDB
+	}
+	if sqlDBConfig.GormEnabled {
+		collection.SqlDBGorm = collection.gormDB
+	}
 
-	return DBCollection{
-		MongoDB:        mongoDB,
-		PostgresDBSqlx: postgresDBSqlx,
-		PostgresDBGorm: postgresDBGorm,
+	// sql read replicas, see SqlxRead and GormRead
+	if len(sqlDBConfig.ReplicaHosts) > 0 {
+		replicas, err := newReplicaResolver(ctx, sqlDBConfig, collection.queryLog)
+		if err != nil {
+			return collection, errors.Join(err, collection.Close(ctx))
+		}
+		collection.replicas = replicas
 	}
+
+	return collection, nil
+}
+
+func retryBackoff(cfg config.DataSourceRetry) tools.Backoff {
+	return tools.Backoff{
+		MaxAttempts:     cfg.MaxAttempts,
+		InitialInterval: time.Second * time.Duration(cfg.InitialInterval),
+		MaxInterval:     time.Second * time.Duration(cfg.MaxInterval),
+	}
+}
+
+// Close releases every datasource held by the collection, skipping the disabled ones
+func (d DBCollection) Close(ctx context.Context) error {
+	var errs []error
+
+	if d.MongoDB != nil {
+		if err := d.MongoDB.Client().Disconnect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error when closing mongodb: %w", err))
+		}
+	}
+
+	// closes the sqlx and gorm handles as well
+	if d.SqlDB != nil {
+		if err := d.SqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error when closing %s: %w", d.sqlDriver, err))
+		}
+	}
+
+	if err := d.replicas.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mongoPoolWaitThreshold is the checkout duration above which a mongo checkout counts as a wait for a connection,
+// the driver reports the duration of every checkout, waiting or not
+const mongoPoolWaitThreshold = time.Millisecond
+
+type (
+	// PoolStats is a snapshot of a datasource connection pool
+	PoolStats struct {
+		Datasource   string `json:"datasource"`
+		MaxOpen      int    `json:"max_open"`
+		Open         int    `json:"open"`
+		InUse        int    `json:"in_use"`
+		Idle         int    `json:"idle"`
+		WaitCount    int64  `json:"wait_count"`
+		WaitDuration string `json:"wait_duration"`
+	}
+
+	// mongoPoolMonitor counts the mongo pool events, the driver does not expose its pool statistics
+	mongoPoolMonitor struct {
+		maxOpen      int
+		open         atomic.Int64
+		inUse        atomic.Int64
+		waitCount    atomic.Int64
+		waitDuration atomic.Int64
+	}
+
+	// PoolStatsCollector collects the statistics of every pool on a schedule, logging them and keeping the latest snapshot
+	PoolStatsCollector struct {
+		db       DBCollection
+		interval time.Duration
+
+		mu     sync.RWMutex
+		latest []PoolStats
+		stop   chan struct{}
+		done   chan struct{}
+	}
+)
+
+func newMongoPoolMonitor(maxOpen int) *mongoPoolMonitor {
+	return &mongoPoolMonitor{maxOpen: maxOpen}
+}
+
+func (m *mongoPoolMonitor) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				m.open.Add(1)
+			case event.ConnectionClosed:
+				m.open.Add(-1)
+			case event.GetSucceeded:
+				m.inUse.Add(1)
+				m.recordWait(e.Duration)
+			case event.GetFailed:
+				m.recordWait(e.Duration)
+			case event.ConnectionReturned:
+				m.inUse.Add(-1)
+			}
+		},
+	}
+}
+
+// recordWait counts a checkout that took longer than mongoPoolWaitThreshold
+func (m *mongoPoolMonitor) recordWait(duration time.Duration) {
+	if duration <= mongoPoolWaitThreshold {
+		return
+	}
+	m.waitCount.Add(1)
+	m.waitDuration.Add(int64(duration))
+}
+
+func (m *mongoPoolMonitor) Stats() PoolStats {
+	open, inUse := int(m.open.Load()), int(m.inUse.Load())
+	return PoolStats{
+		Datasource:   "mongodb",
+		MaxOpen:      m.maxOpen,
+		Open:         open,
+		InUse:        inUse,
+		Idle:         max(open-inUse, 0),
+		WaitCount:    m.waitCount.Load(),
+		WaitDuration: time.Duration(m.waitDuration.Load()).String(),
+	}
+}
+
+func sqlPoolStats(datasource string, db *sql.DB) PoolStats {
+	stats := db.Stats()
+	return PoolStats{
+		Datasource:   datasource,
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration.String(),
+	}
+}
+
+// PoolStats returns the current statistics of every open pool
+func (d DBCollection) PoolStats() []PoolStats {
+	var stats []PoolStats
+
+	if d.MongoDB != nil && d.mongoPool != nil {
+		stats = append(stats, d.mongoPool.Stats())
+	}
+
+	if d.SqlDB != nil {
+		stats = append(stats, sqlPoolStats(d.sqlDriver, d.SqlDB))
+	}
+
+	if d.replicas != nil {
+		for _, r := range d.replicas.replicas {
+			stats = append(stats, sqlPoolStats(r.datasource(), r.db))
+		}
+	}
+
+	return stats
+}
+
+func NewPoolStatsCollector(db DBCollection, interval time.Duration) *PoolStatsCollector {
+	return &PoolStatsCollector{
+		db:       db,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start collects the statistics every interval until Stop is called, it does nothing when the interval is not positive
+func (c *PoolStatsCollector) Start() {
+	if c.interval <= 0 {
+		close(c.done)
+		return
+	}
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.collect()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *PoolStatsCollector) collect() {
+	stats := c.db.PoolStats()
+
+	c.mu.Lock()
+	c.latest = stats
+	c.mu.Unlock()
+
+	for _, s := range stats {
+		logrus.WithFields(logrus.Fields{
+			"datasource":    s.Datasource,
+			"max_open":      s.MaxOpen,
+			"open":          s.Open,
+			"in_use":        s.InUse,
+			"idle":          s.Idle,
+			"wait_count":    s.WaitCount,
+			"wait_duration": s.WaitDuration,
+		}).Info("db pool stats")
+	}
+}
+
+// Latest returns the statistics of the last collection, or the current ones when nothing was collected yet
+func (c *PoolStatsCollector) Latest() []PoolStats {
+	c.mu.RLock()
+	latest := c.latest
+	c.mu.RUnlock()
+
+	if latest == nil {
+		return c.db.PoolStats()
+	}
+	return latest
+}
+
+// Stop stops the collection, it is meant to be registered as a shutdown hook
+func (c *PoolStatsCollector) Stop(ctx context.Context) error {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
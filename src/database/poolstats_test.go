@@ -0,0 +1,37 @@
+package database
+
+import (
+	"go.mongodb.org/mongo-driver/event"
+	"testing"
+	"time"
+)
+
+func TestMongoPoolMonitorCountsWaits(t *testing.T) {
+	monitor := newMongoPoolMonitor(10)
+	poolMonitor := monitor.PoolMonitor()
+
+	events := []*event.PoolEvent{
+		{Type: event.ConnectionCreated},
+		{Type: event.ConnectionCreated},
+		{Type: event.GetSucceeded, Duration: 100 * time.Microsecond},
+		{Type: event.GetSucceeded, Duration: 5 * time.Millisecond},
+		{Type: event.ConnectionReturned},
+		{Type: event.GetFailed, Duration: 30 * time.Millisecond},
+	}
+	for _, e := range events {
+		poolMonitor.Event(e)
+	}
+
+	want := PoolStats{
+		Datasource:   "mongodb",
+		MaxOpen:      10,
+		Open:         2,
+		InUse:        1,
+		Idle:         1,
+		WaitCount:    2,
+		WaitDuration: (35 * time.Millisecond).String(),
+	}
+	if got := monitor.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
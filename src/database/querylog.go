@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redactedValue = "?"
+
+var (
+	// mongoUnloggedCommands are the handshake, session and health commands, noise next to the queries
+	mongoUnloggedCommands = map[string]bool{
+		"hello":        true,
+		"isMaster":     true,
+		"ismaster":     true,
+		"ping":         true,
+		"buildInfo":    true,
+		"endSessions":  true,
+		"saslStart":    true,
+		"saslContinue": true,
+		"authenticate": true,
+		"getnonce":     true,
+	}
+
+	// mongoUnloggedFields are the top-level fields of a command describing its session rather than the query
+	mongoUnloggedFields = map[string]bool{
+		"lsid":             true,
+		"txnNumber":        true,
+		"autocommit":       true,
+		"startTransaction": true,
+		"readConcern":      true,
+		"writeConcern":     true,
+	}
+)
+
+type (
+	// mongoCommandLog logs the mongodb commands, correlating their start with their outcome
+	mongoCommandLog struct {
+		queryLog *tools.QueryLog
+		started  sync.Map
+	}
+
+	mongoCommandKey struct {
+		connectionID string
+		requestID    int64
+	}
+)
+
+// newQueryLog builds the query log of cfg, nil when disabled
+func newQueryLog(cfg config.QueryLog) *tools.QueryLog {
+	if !cfg.Enabled {
+		return nil
+	}
+	return tools.NewQueryLog(time.Millisecond * time.Duration(cfg.SlowThreshold))
+}
+
+// newMongoCommandMonitor logs the commands of a mongodb client to queryLog, nil when queryLog is nil
+func newMongoCommandMonitor(queryLog *tools.QueryLog) *event.CommandMonitor {
+	if queryLog == nil {
+		return nil
+	}
+
+	commandLog := &mongoCommandLog{queryLog: queryLog}
+	return &event.CommandMonitor{
+		Started:   commandLog.commandStarted,
+		Succeeded: commandLog.commandSucceeded,
+		Failed:    commandLog.commandFailed,
+	}
+}
+
+func (m *mongoCommandLog) commandStarted(_ context.Context, evt *event.CommandStartedEvent) {
+	if mongoUnloggedCommands[evt.CommandName] {
+		return
+	}
+	m.started.Store(mongoCommandKey{evt.ConnectionID, evt.RequestID}, redactMongoCommand(evt.CommandName, evt.Command))
+}
+
+func (m *mongoCommandLog) commandSucceeded(ctx context.Context, evt *event.CommandSucceededEvent) {
+	m.log(ctx, evt.CommandFinishedEvent, mongoReplyRows(evt.Reply), nil)
+}
+
+func (m *mongoCommandLog) commandFailed(ctx context.Context, evt *event.CommandFailedEvent) {
+	m.log(ctx, evt.CommandFinishedEvent, -1, errors.New(evt.Failure))
+}
+
+func (m *mongoCommandLog) log(ctx context.Context, evt event.CommandFinishedEvent, rows int64, err error) {
+	statement, ok := m.started.LoadAndDelete(mongoCommandKey{evt.ConnectionID, evt.RequestID})
+	if !ok {
+		return
+	}
+
+	m.queryLog.Log(ctx, tools.Query{
+		Datasource: "mongodb",
+		Statement:  statement.(string),
+		Duration:   evt.Duration,
+		Rows:       rows,
+		Err:        err,
+	})
+}
+
+// redactMongoCommand renders a command as extended JSON, keeping the names and options (collection, limit...)
+// but replacing every value of its documents (filter, update, documents, pipeline...) with ?
+func redactMongoCommand(name string, command bson.Raw) string {
+	elements, err := command.Elements()
+	if err != nil {
+		return name
+	}
+
+	redacted := make(bson.D, 0, len(elements))
+	for _, element := range elements {
+		key := element.Key()
+		if strings.HasPrefix(key, "$") || mongoUnloggedFields[key] {
+			continue
+		}
+
+		value := element.Value()
+		switch value.Type {
+		case bsontype.EmbeddedDocument, bsontype.Array:
+			redacted = append(redacted, bson.E{Key: key, Value: redactMongoValue(value)})
+		default:
+			redacted = append(redacted, bson.E{Key: key, Value: value})
+		}
+	}
+
+	rendered, err := bson.MarshalExtJSON(redacted, false, false)
+	if err != nil {
+		return name
+	}
+	return fmt.Sprintf("%s %s", name, rendered)
+}
+
+// redactMongoValue keeps the structure of documents and arrays, replacing their values with ?
+func redactMongoValue(value bson.RawValue) interface{} {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		redacted := make(bson.D, len(elements))
+		for i, element := range elements {
+			redacted[i] = bson.E{Key: element.Key(), Value: redactMongoValue(element.Value())}
+		}
+		return redacted
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		redacted := make(bson.A, len(values))
+		for i, item := range values {
+			redacted[i] = redactMongoValue(item)
+		}
+		return redacted
+	default:
+		return redactedValue
+	}
+}
+
+// mongoReplyRows returns the documents affected (n) or returned in the first batch of a command, -1 when unknown
+func mongoReplyRows(reply bson.Raw) int64 {
+	if n, err := reply.LookupErr("n"); err == nil {
+		if rows, ok := n.AsInt64OK(); ok {
+			return rows
+		}
+	}
+
+	for _, batch := range []string{"firstBatch", "nextBatch"} {
+		documents, err := reply.LookupErr("cursor", batch)
+		if err != nil {
+			continue
+		}
+		if array, ok := documents.ArrayOK(); ok {
+			values, _ := array.Values()
+			return int64(len(values))
+		}
+	}
+
+	return -1
+}
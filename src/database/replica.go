@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+type (
+	// replica is a read-only SQL pool, out of rotation while unhealthy
+	replica struct {
+		driver  string
+		host    string
+		db      *sql.DB
+		sqlx    *sqlx.DB
+		gorm    *gorm.DB
+		healthy atomic.Bool
+	}
+
+	// ReplicaResolver spreads the reads across the healthy replicas (round robin),
+	// falling back to the primary when none is configured or healthy
+	ReplicaResolver struct {
+		replicas []*replica
+		next     atomic.Uint64
+	}
+
+	primaryContextKey struct{}
+)
+
+// WithPrimary sends the reads of ctx to the primary, e.g. to read your own writes right after a mutation
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// newReplicaResolver opens a pool for every replica host. A replica failing its first ping is kept out of rotation
+// instead of failing the startup, the Monitor puts it back once it answers.
+func newReplicaResolver(ctx context.Context, cfg config.SqlDBConfig, queryLog *tools.QueryLog) (*ReplicaResolver, error) {
+	resolver := &ReplicaResolver{}
+	pool := sqlPoolConfig(cfg)
+
+	for _, host := range cfg.ReplicaHosts {
+		db, err := tools.OpenSqlDB(cfg.Driver, cfg.DSNForHost(host))
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("error when opening %s replica %s, error: %w", cfg.Driver, host, err), resolver.Close())
+		}
+		tools.SetupSqlPool(db, pool)
+
+		r := &replica{driver: cfg.Driver, host: host, db: db}
+		resolver.replicas = append(resolver.replicas, r)
+
+		// both handles are built like the primary ones, SqlxRead and GormRead serve either API whatever is enabled
+		r.sqlx = InitializeSqlDatabaseSqlx(db, cfg.Driver)
+		if r.gorm, err = InitializeSqlDatabaseGorm(ctx, cfg.Driver, db, tools.NewGormLogger(queryLog, r.datasource())); err != nil {
+			return nil, errors.Join(err, resolver.Close())
+		}
+
+		if err := db.PingContext(ctx); err != nil {
+			logrus.Warnf("%s replica %s is out of rotation, error: %v", cfg.Driver, host, err)
+			continue
+		}
+		r.healthy.Store(true)
+	}
+
+	return resolver, nil
+}
+
+// pick returns the next healthy replica, nil when there is none
+func (r *ReplicaResolver) pick() *replica {
+	if r == nil || len(r.replicas) == 0 {
+		return nil
+	}
+
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, candidate := range r.replicas {
+		if candidate.healthy.Load() {
+			healthy = append(healthy, candidate)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	return healthy[r.next.Add(1)%uint64(len(healthy))]
+}
+
+// datasource names the replica in the pool stats and query logs
+func (r *replica) datasource() string {
+	return r.driver + "_replica_" + r.host
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			logrus.Printf("[INFO] %s replica %s is back in rotation", r.driver, r.host)
+		} else {
+			logrus.Warnf("%s replica %s is out of rotation", r.driver, r.host)
+		}
+	}
+}
+
+func (r *ReplicaResolver) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, replica := range r.replicas {
+		if err := replica.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error when closing %s replica %s: %w", replica.driver, replica.host, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readReplica returns the replica serving the reads of ctx, nil when they must go to the primary
+func (d DBCollection) readReplica(ctx context.Context) *replica {
+	if _, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return nil
+	}
+	if primary, _ := ctx.Value(primaryContextKey{}).(bool); primary {
+		return nil
+	}
+	return d.replicas.pick()
+}
+
+// SqlxRead returns a sqlx handle for read-only queries: the transaction of ctx, the primary when requested by WithPrimary,
+// otherwise a healthy replica (the primary when there is none)
+func (d DBCollection) SqlxRead(ctx context.Context) sqlx.ExtContext {
+	if replica := d.readReplica(ctx); replica != nil {
+		return tools.SqlxWithQueryLog(replica.sqlx, d.queryLog, replica.datasource())
+	}
+	return d.Sqlx(ctx)
+}
+
+// GormRead returns a gorm handle for read-only queries, routed like SqlxRead
+func (d DBCollection) GormRead(ctx context.Context) *gorm.DB {
+	if replica := d.readReplica(ctx); replica != nil {
+		return replica.gorm.WithContext(ctx)
+	}
+	return d.Gorm(ctx)
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"go-chi-boilerplate/src/config"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplicaResolverHandles(t *testing.T) {
+	tests := []struct {
+		name        string
+		sqlxEnabled bool
+		gormEnabled bool
+	}{
+		{name: "sqlx only", sqlxEnabled: true},
+		{name: "gorm only", gormEnabled: true},
+		{name: "neither", sqlxEnabled: false, gormEnabled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := config.SqlDBConfig{
+				Driver:       "sqlite",
+				Name:         filepath.Join(t.TempDir(), "replica.db"),
+				ReplicaHosts: []string{"replica"},
+				SqlxEnabled:  tt.sqlxEnabled,
+				GormEnabled:  tt.gormEnabled,
+			}
+			resolver, err := newReplicaResolver(ctx, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resolver.Close()
+
+			replica := resolver.replicas[0]
+			if replica.sqlx == nil || replica.gorm == nil {
+				t.Fatalf("replica handles sqlx = %v, gorm = %v, want both", replica.sqlx, replica.gorm)
+			}
+
+			// the reads go to the replica through either API
+			db := DBCollection{replicas: resolver}
+			if got := db.SqlxRead(ctx); got != replica.sqlx {
+				t.Errorf("SqlxRead() = %v, want the replica", got)
+			}
+			if got, err := db.GormRead(ctx).DB(); err != nil || got != replica.db {
+				t.Errorf("GormRead() pool = %v, want the replica, error: %v", got, err)
+			}
+		})
+	}
+}
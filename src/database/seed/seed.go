@@ -0,0 +1,512 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DATASOURCE_SQL     = "sql" // the SQL datasource, whatever its driver
+	DATASOURCE_MONGODB = "mongodb"
+	// Deprecated: DATASOURCE_POSTGRES is the former name of DATASOURCE_SQL, still read from the fixtures
+	DATASOURCE_POSTGRES = "postgres"
+
+	// REF_FIELD names a row, so that the following rows can reference its fields
+	REF_FIELD = "_ref"
+	// REF_PREFIX marks a value referencing a field of a named row, e.g. $ref:example_alpha.id
+	REF_PREFIX = "$ref:"
+)
+
+var fixtureExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+type (
+	// Fixture is a set of rows of a SQL table or documents of a mongodb collection, a fixture file holds a list of them.
+	// Key lists the columns (fields) identifying a row: re-runs update the existing row instead of inserting it again.
+	// Without a key the rows are inserted on every run.
+	//
+	//	Usage example:
+	//		- datasource: sql
+	//		  table: examples
+	//		  key: [name]
+	//		  rows:
+	//		    - _ref: example_alpha
+	//		      name: alpha
+	//		- datasource: mongodb
+	//		  table: examples
+	//		  key: [name]
+	//		  rows:
+	//		    - name: alpha
+	//		      example_id: $ref:example_alpha.id
+	Fixture struct {
+		Datasource string                   `yaml:"datasource" json:"datasource"`
+		Table      string                   `yaml:"table" json:"table"`
+		Key        []string                 `yaml:"key" json:"key"`
+		Rows       []map[string]interface{} `yaml:"rows" json:"rows"`
+
+		file string
+	}
+
+	Options struct {
+		// Truncate empties every table and collection of the fixtures before seeding them.
+		// The mongodb collections are emptied outside of the SQL transaction, a failed seed leaves them empty.
+		Truncate bool
+		// Cascade also empties the SQL tables referencing the truncated ones (TRUNCATE ... CASCADE),
+		// without it truncating a referenced table fails
+		Cascade bool
+	}
+
+	// Result counts the rows seeded into a table or collection
+	Result struct {
+		Datasource string
+		Table      string
+		Inserted   int
+		Updated    int
+	}
+
+	seeder struct {
+		db      database.DBCollection
+		refs    map[string]map[string]interface{}
+		results []Result
+	}
+)
+
+// Load reads the fixture files of dir (*.yaml, *.yml and *.json), then the ones of the env set dir/<env> (e.g. fixtures/dev),
+// each sorted by name. A missing env set is not an error.
+func Load(dir, env string) ([]Fixture, error) {
+	fixtures, err := loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	envDir := filepath.Join(dir, strings.ToLower(env))
+	if info, err := os.Stat(envDir); env != "" && err == nil && info.IsDir() {
+		envFixtures, err := loadDir(envDir)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, envFixtures...)
+	}
+
+	if err := validate(fixtures); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+func loadDir(dir string) ([]Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading fixtures %s, error: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && fixtureExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	var fixtures []Fixture
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading fixture %s, error: %w", file, err)
+		}
+
+		// JSON is valid YAML
+		var fileFixtures []Fixture
+		if err := yaml.Unmarshal(content, &fileFixtures); err != nil {
+			return nil, fmt.Errorf("error when parsing fixture %s, error: %w", file, err)
+		}
+		for i := range fileFixtures {
+			fileFixtures[i].file = file
+			if fileFixtures[i].Datasource == DATASOURCE_POSTGRES {
+				fileFixtures[i].Datasource = DATASOURCE_SQL
+			}
+		}
+		fixtures = append(fixtures, fileFixtures...)
+	}
+
+	return fixtures, nil
+}
+
+// validate checks the datasources, the keys and that every reference names a previous row
+func validate(fixtures []Fixture) error {
+	refs := map[string]bool{}
+	for _, fixture := range fixtures {
+		if fixture.Datasource != DATASOURCE_SQL && fixture.Datasource != DATASOURCE_MONGODB {
+			return fmt.Errorf("fixture %s: invalid datasource %q, valid choices are: %s, %s", fixture.file, fixture.Datasource, DATASOURCE_SQL, DATASOURCE_MONGODB)
+		}
+		if fixture.Table == "" {
+			return fmt.Errorf("fixture %s: missing table", fixture.file)
+		}
+
+		for i, row := range fixture.Rows {
+			for _, key := range fixture.Key {
+				if _, ok := row[key]; !ok {
+					return fmt.Errorf("fixture %s: row %d of %s has no key %s", fixture.file, i, fixture.Table, key)
+				}
+			}
+			if err := validateRefs(row, refs); err != nil {
+				return fmt.Errorf("fixture %s: row %d of %s: %w", fixture.file, i, fixture.Table, err)
+			}
+
+			if ref, ok := row[REF_FIELD]; ok {
+				name, _ := ref.(string)
+				if name == "" || refs[name] {
+					return fmt.Errorf("fixture %s: row %d of %s: invalid or duplicate %s %v", fixture.file, i, fixture.Table, REF_FIELD, ref)
+				}
+				refs[name] = true
+			}
+		}
+	}
+	return nil
+}
+
+func validateRefs(value interface{}, refs map[string]bool) error {
+	switch value := value.(type) {
+	case string:
+		if strings.HasPrefix(value, REF_PREFIX) {
+			name, _, ok := strings.Cut(strings.TrimPrefix(value, REF_PREFIX), ".")
+			if !ok || !refs[name] {
+				return fmt.Errorf("reference %q does not name a previous row field", value)
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			if err := validateRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := validateRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Seed writes the fixtures in order through the DBCollection handles. The SQL rows are written in a single
+// transaction, the mongodb documents as they come (no session, mongodb transactions need a replica set),
+// so a failure rolls back the SQL rows but keeps the documents written or deleted so far.
+func Seed(ctx context.Context, db database.DBCollection, fixtures []Fixture, opts Options) ([]Result, error) {
+	s := &seeder{
+		db:   db,
+		refs: map[string]map[string]interface{}{},
+	}
+
+	var usesSql, usesMongoDB bool
+	for _, fixture := range fixtures {
+		usesSql = usesSql || fixture.Datasource == DATASOURCE_SQL
+		usesMongoDB = usesMongoDB || fixture.Datasource == DATASOURCE_MONGODB
+	}
+	if usesSql && db.SqlDB == nil {
+		return nil, errors.New("the fixtures seed the SQL datasource, which is not enabled")
+	}
+	if usesMongoDB && db.MongoDB == nil {
+		return nil, errors.New("the fixtures seed mongodb, which is not enabled")
+	}
+
+	seed := func(ctx context.Context) error {
+		if opts.Truncate {
+			if err := s.truncate(ctx, fixtures, opts.Cascade); err != nil {
+				return err
+			}
+		}
+		for _, fixture := range fixtures {
+			if err := s.seed(ctx, fixture); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if usesSql {
+		err = database.NewTxManager(db, 0).WithinTx(ctx, seed)
+	} else {
+		err = seed(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.results, nil
+}
+
+// truncate empties the tables and collections of fixtures, the collections right away as they are not in the transaction
+func (s *seeder) truncate(ctx context.Context, fixtures []Fixture, cascade bool) error {
+	var tables []string
+	truncated := map[string]bool{}
+	for _, fixture := range fixtures {
+		key := fixture.Datasource + "." + fixture.Table
+		if truncated[key] {
+			continue
+		}
+		truncated[key] = true
+
+		switch fixture.Datasource {
+		case DATASOURCE_SQL:
+			tables = append(tables, fixture.Table)
+		case DATASOURCE_MONGODB:
+			result, err := s.db.MongoDB.Collection(fixture.Table).DeleteMany(ctx, bson.M{})
+			if err != nil {
+				return fmt.Errorf("error when truncating mongodb %s, error: %w", fixture.Table, err)
+			}
+			logrus.Printf("[INFO] truncated mongodb %s (%d documents)", fixture.Table, result.DeletedCount)
+		}
+	}
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	db := s.db.Sqlx(ctx)
+	dialect := tools.SqlDialect(db.DriverName())
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = tools.QuoteSqlIdentifier(dialect, table)
+	}
+
+	// mysql would commit the transaction on TRUNCATE and sqlite has none, both keep their id sequences
+	// and fail on the rows referencing the deleted ones when their foreign keys are enforced
+	truncate := fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY", strings.Join(quoted, ", "))
+	if cascade {
+		truncate += " CASCADE"
+	}
+	statements := []string{truncate}
+	if dialect != tools.DIALECT_POSTGRES {
+		statements = statements[:0]
+		for i := len(quoted) - 1; i >= 0; i-- {
+			statements = append(statements, "DELETE FROM "+quoted[i])
+		}
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error when truncating %s, error: %w", strings.Join(tables, ", "), err)
+		}
+	}
+	logrus.Printf("[INFO] truncated %s", strings.Join(tables, ", "))
+	return nil
+}
+
+func (s *seeder) seed(ctx context.Context, fixture Fixture) error {
+	result := Result{Datasource: fixture.Datasource, Table: fixture.Table}
+
+	for i, row := range fixture.Rows {
+		ref, _ := row[REF_FIELD].(string)
+		values := make(map[string]interface{}, len(row))
+		for column, value := range row {
+			if column != REF_FIELD {
+				values[column] = s.resolve(value, fixture.Datasource)
+			}
+		}
+
+		var (
+			stored   map[string]interface{}
+			inserted bool
+			err      error
+		)
+		switch fixture.Datasource {
+		case DATASOURCE_SQL:
+			stored, inserted, err = s.seedSql(ctx, fixture, values)
+		case DATASOURCE_MONGODB:
+			stored, inserted, err = s.seedMongoDB(ctx, fixture, values)
+		}
+		if err != nil {
+			return fmt.Errorf("error when seeding row %d of %s %s (%s), error: %w", i, fixture.Datasource, fixture.Table, fixture.file, err)
+		}
+
+		if inserted {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+		if ref != "" {
+			s.refs[ref] = stored
+		}
+	}
+
+	s.results = append(s.results, result)
+	return nil
+}
+
+// seedSql updates the row matching the key, inserting it when there is none, and returns the stored row
+func (s *seeder) seedSql(ctx context.Context, fixture Fixture, values map[string]interface{}) (map[string]interface{}, bool, error) {
+	db := s.db.Sqlx(ctx)
+	dialect := tools.SqlDialect(db.DriverName())
+	table := tools.QuoteSqlIdentifier(dialect, fixture.Table)
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := make([]interface{}, len(columns))
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		args[i], quoted[i] = values[column], tools.QuoteSqlIdentifier(dialect, column)
+	}
+
+	var (
+		where     string
+		whereArgs []interface{}
+	)
+	if len(fixture.Key) > 0 {
+		assignments := make([]string, len(columns))
+		for i := range columns {
+			assignments[i] = quoted[i] + " = ?"
+		}
+		conditions := make([]string, len(fixture.Key))
+		for i, key := range fixture.Key {
+			conditions[i] = tools.QuoteSqlIdentifier(dialect, key) + " = ?"
+			whereArgs = append(whereArgs, values[key])
+		}
+		where = strings.Join(conditions, " AND ")
+
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(assignments, ", "), where)
+		stored, err := write(ctx, db, table, query, append(args, whereArgs...), where, whereArgs)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return stored, false, err
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ", "), placeholders)
+	stored, err := write(ctx, db, table, query, args, where, whereArgs)
+	return stored, true, err
+}
+
+// write runs an INSERT or UPDATE and returns the written row, read with RETURNING or, with mysql which lacks it,
+// by where (by the generated id when where is empty). It returns sql.ErrNoRows when the statement writes no row.
+func write(ctx context.Context, db sqlx.ExtContext, table, query string, args []interface{}, where string, whereArgs []interface{}) (map[string]interface{}, error) {
+	stored := map[string]interface{}{}
+	dialect := tools.SqlDialect(db.DriverName())
+	if dialect != tools.DIALECT_MYSQL {
+		if err := db.QueryRowxContext(ctx, db.Rebind(query+" RETURNING *"), args...).MapScan(stored); err != nil {
+			return nil, err
+		}
+		return normalizeRow(stored), nil
+	}
+
+	result, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	if where == "" {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		where, whereArgs = tools.QuoteSqlIdentifier(dialect, "id")+" = ?", []interface{}{id}
+	}
+	query = fmt.Sprintf("SELECT * FROM %s WHERE %s", table, where)
+	if err := db.QueryRowxContext(ctx, db.Rebind(query), whereArgs...).MapScan(stored); err != nil {
+		return nil, err
+	}
+	return normalizeRow(stored), nil
+}
+
+// seedMongoDB replaces the document matching the key, inserting it when there is none, and returns the stored document
+func (s *seeder) seedMongoDB(ctx context.Context, fixture Fixture, values map[string]interface{}) (map[string]interface{}, bool, error) {
+	collection := s.db.MongoDB.Collection(fixture.Table)
+	document := bson.M(values)
+
+	if len(fixture.Key) == 0 {
+		result, err := collection.InsertOne(ctx, document)
+		if err != nil {
+			return nil, false, err
+		}
+		document["_id"] = result.InsertedID
+		return document, true, nil
+	}
+
+	filter := bson.M{}
+	for _, key := range fixture.Key {
+		filter[key] = values[key]
+	}
+
+	result, err := collection.ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var stored bson.M
+	if err := collection.FindOne(ctx, filter).Decode(&stored); err != nil {
+		return nil, false, err
+	}
+	return stored, result.UpsertedID != nil, nil
+}
+
+// resolve replaces the references of value by the fields they name, encoding the documents and arrays as JSON for the SQL datasource
+func (s *seeder) resolve(value interface{}, datasource string) interface{} {
+	resolved := s.resolveRefs(value)
+	if datasource != DATASOURCE_SQL {
+		return resolved
+	}
+
+	switch resolved := resolved.(type) {
+	case primitive.ObjectID:
+		return resolved.Hex()
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(resolved)
+		return string(encoded)
+	}
+	return resolved
+}
+
+func (s *seeder) resolveRefs(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if !strings.HasPrefix(value, REF_PREFIX) {
+			return value
+		}
+		name, field, _ := strings.Cut(strings.TrimPrefix(value, REF_PREFIX), ".")
+		return s.refs[name][field]
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			resolved[key] = s.resolveRefs(item)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(value))
+		for i, item := range value {
+			resolved[i] = s.resolveRefs(item)
+		}
+		return resolved
+	}
+	return value
+}
+
+// normalizeRow turns the []byte values scanned by the drivers (e.g. numeric) into strings, usable as references
+func normalizeRow(row map[string]interface{}) map[string]interface{} {
+	for column, value := range row {
+		if bytes, ok := value.([]byte); ok {
+			row[column] = string(bytes)
+		}
+	}
+	return row
+}
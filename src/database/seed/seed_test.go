@@ -0,0 +1,258 @@
+package seed
+
+import (
+	"context"
+	"go-chi-boilerplate/src/database/databasetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		env     string
+		want    []string // the datasource.table of the loaded fixtures, in order
+		wantErr string
+	}{
+		{
+			name: "shared then env set",
+			files: map[string]string{
+				"b.yaml":     "- {datasource: sql, table: b, rows: [{_ref: b1, name: b}]}",
+				"a.json":     `[{"datasource": "mongodb", "table": "a", "rows": [{"name": "a"}]}]`,
+				"dev/c.yml":  "- {datasource: sql, table: c, rows: [{b_id: $ref:b1.id}]}",
+				"prod/d.yml": "- {datasource: sql, table: d}",
+				"notes.txt":  "ignored",
+			},
+			env:  "DEV",
+			want: []string{"mongodb.a", "sql.b", "sql.c"},
+		},
+		{
+			name:  "missing env set",
+			files: map[string]string{"a.yaml": "- {datasource: sql, table: a}"},
+			env:   "staging",
+			want:  []string{"sql.a"},
+		},
+		{
+			name:  "former name of the sql datasource",
+			files: map[string]string{"a.yaml": "- {datasource: postgres, table: a}"},
+			want:  []string{"sql.a"},
+		},
+		{
+			name:    "invalid datasource",
+			files:   map[string]string{"a.yaml": "- {datasource: redis, table: a}"},
+			wantErr: `invalid datasource "redis"`,
+		},
+		{
+			name:    "missing key",
+			files:   map[string]string{"a.yaml": "- {datasource: sql, table: a, key: [name], rows: [{id: 1}]}"},
+			wantErr: "has no key name",
+		},
+		{
+			name:    "reference to a later row",
+			files:   map[string]string{"a.yaml": "- {datasource: sql, table: a, rows: [{b_id: $ref:b1.id}, {_ref: b1}]}"},
+			wantErr: `reference "$ref:b1.id" does not name a previous row field`,
+		},
+		{
+			name:    "nested reference without field",
+			files:   map[string]string{"a.yaml": "- {datasource: sql, table: a, rows: [{_ref: a1}, {tags: [{id: $ref:a1}]}]}"},
+			wantErr: `reference "$ref:a1" does not name a previous row field`,
+		},
+		{
+			name:    "duplicate ref",
+			files:   map[string]string{"a.yaml": "- {datasource: sql, table: a, rows: [{_ref: a1}, {_ref: a1}]}"},
+			wantErr: "invalid or duplicate _ref a1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fixtures, err := Load(dir, tt.env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, fixture := range fixtures {
+				got = append(got, fixture.Datasource+"."+fixture.Table)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeederResolve(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	s := &seeder{refs: map[string]map[string]interface{}{
+		"alpha": {"id": int64(7), "name": "alpha"},
+		"doc":   {"_id": objectID},
+	}}
+
+	tests := []struct {
+		name       string
+		value      interface{}
+		datasource string
+		want       interface{}
+	}{
+		{name: "plain value", value: "alpha", datasource: DATASOURCE_SQL, want: "alpha"},
+		{name: "reference", value: "$ref:alpha.id", datasource: DATASOURCE_SQL, want: int64(7)},
+		{name: "object id for sql", value: "$ref:doc._id", datasource: DATASOURCE_SQL, want: objectID.Hex()},
+		{name: "object id for mongodb", value: "$ref:doc._id", datasource: DATASOURCE_MONGODB, want: objectID},
+		{
+			name:       "nested for sql, encoded once",
+			value:      map[string]interface{}{"ids": []interface{}{"$ref:alpha.id", []interface{}{"$ref:alpha.name"}}},
+			datasource: DATASOURCE_SQL,
+			want:       `{"ids":[7,["alpha"]]}`,
+		},
+		{
+			name:       "nested for mongodb",
+			value:      []interface{}{map[string]interface{}{"id": "$ref:alpha.id"}},
+			datasource: DATASOURCE_MONGODB,
+			want:       []interface{}{map[string]interface{}{"id": int64(7)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.resolve(tt.value, tt.datasource); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolve() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeedSqlite(t *testing.T) {
+	fixtures := `
+- datasource: sql
+  table: parents
+  key: [name]
+  rows:
+    - {_ref: alpha, name: alpha}
+    - {_ref: beta, name: beta}
+- datasource: sql
+  table: children
+  key: [note]
+  rows:
+    - {note: first, parent_id: $ref:alpha.id}
+    - {note: second, parent_id: $ref:beta.id}
+`
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		opts        Options
+		runs        int
+		want        []Result // of the last run
+		wantErr     string
+		wantParents []string
+	}{
+		{
+			name:        "inserted",
+			files:       map[string]string{"a.yaml": fixtures},
+			runs:        1,
+			want:        []Result{{DATASOURCE_SQL, "parents", 2, 0}, {DATASOURCE_SQL, "children", 2, 0}},
+			wantParents: []string{"alpha", "beta", "stale"},
+		},
+		{
+			name:        "updated on re-runs",
+			files:       map[string]string{"a.yaml": fixtures},
+			runs:        2,
+			want:        []Result{{DATASOURCE_SQL, "parents", 0, 2}, {DATASOURCE_SQL, "children", 0, 2}},
+			wantParents: []string{"alpha", "beta", "stale"},
+		},
+		{
+			name:        "truncated first",
+			files:       map[string]string{"a.yaml": fixtures},
+			opts:        Options{Truncate: true},
+			runs:        2,
+			want:        []Result{{DATASOURCE_SQL, "parents", 2, 0}, {DATASOURCE_SQL, "children", 2, 0}},
+			wantParents: []string{"alpha", "beta"},
+		},
+		{
+			name:        "rolled back on failure",
+			files:       map[string]string{"a.yaml": fixtures, "b.yaml": "- {datasource: sql, table: missing, rows: [{name: x}]}"},
+			runs:        1,
+			wantErr:     "error when seeding row 0 of sql missing",
+			wantParents: []string{"stale"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := databasetest.OpenSqlite(t)
+			for _, statement := range []string{
+				"CREATE TABLE parents (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)",
+				"CREATE TABLE children (id INTEGER PRIMARY KEY AUTOINCREMENT, note TEXT NOT NULL, parent_id INTEGER NOT NULL REFERENCES parents (id))",
+				"INSERT INTO parents (name) VALUES ('stale')",
+			} {
+				if _, err := db.SqlDB.ExecContext(ctx, statement); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			loaded, err := Load(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var results []Result
+			for i := 0; i < tt.runs; i++ {
+				results, err = Seed(ctx, db, loaded, tt.opts)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Seed() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("Seed() = %+v, want %+v", results, tt.want)
+			}
+
+			var parents []string
+			if err := db.SqlDBSqlx.SelectContext(ctx, &parents, "SELECT name FROM parents ORDER BY name"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parents, tt.wantParents) {
+				t.Errorf("parents = %v, want %v", parents, tt.wantParents)
+			}
+
+			// every child references the parent of its fixture
+			var orphans int
+			query := "SELECT COUNT(*) FROM children c JOIN parents p ON p.id = c.parent_id WHERE p.name != CASE c.note WHEN 'first' THEN 'alpha' ELSE 'beta' END"
+			if err := db.SqlDBSqlx.GetContext(ctx, &orphans, query); err != nil {
+				t.Fatal(err)
+			}
+			if orphans != 0 {
+				t.Errorf("%d children reference the wrong parent", orphans)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"time"
+)
+
+// SQL drivers, chosen by SQL_DRIVER
+const (
+	DRIVER_POSTGRES = "postgres" // lib/pq
+	DRIVER_PGX      = "pgx"      // jackc/pgx
+	DRIVER_MYSQL    = "mysql"    // go-sql-driver/mysql
+	DRIVER_SQLITE   = "sqlite"   // glebarez/go-sqlite, the pure-Go modernc.org/sqlite
+)
+
+// former names of the SQL initializers
+var (
+	// InitializePostgresqlDatabase is the former name of InitializeSqlDatabase.
+	//
+	// Deprecated: use InitializeSqlDatabase
+	InitializePostgresqlDatabase = InitializeSqlDatabase
+	// InitializePostgresqlDatabaseSqlx is the former name of InitializeSqlDatabaseSqlx.
+	//
+	// Deprecated: use InitializeSqlDatabaseSqlx
+	InitializePostgresqlDatabaseSqlx = InitializeSqlDatabaseSqlx
+	// InitializePostgresqlDatabaseGorm is the former name of InitializeSqlDatabaseGorm.
+	//
+	// Deprecated: use InitializeSqlDatabaseGorm
+	InitializePostgresqlDatabaseGorm = InitializeSqlDatabaseGorm
+)
+
+// InitializeSqlDatabase opens the SQL pool of driver shared by the sqlx and gorm handles
+func InitializeSqlDatabase(ctx context.Context, driver, dsn string, pool tools.SqlPoolConfig) (*sql.DB, error) {
+	return tools.NewSqlDB(ctx, driver, dsn, pool)
+}
+
+func InitializeSqlDatabaseSqlx(db *sql.DB, driver string) *sqlx.DB {
+	return tools.NewSqlxDB(db, driver)
+}
+
+func InitializeSqlDatabaseGorm(ctx context.Context, driver string, db *sql.DB, logger gormlogger.Interface) (*gorm.DB, error) {
+	return tools.NewGormDB(ctx, driver, db, logger)
+}
+
+func sqlPoolConfig(cfg config.SqlDBConfig) tools.SqlPoolConfig {
+	return tools.SqlPoolConfig{
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: time.Second * time.Duration(cfg.ConnMaxLifetime),
+		ConnMaxIdleTime: time.Second * time.Duration(cfg.ConnMaxIdleTime),
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go-chi-boilerplate/src/tools"
+	"gorm.io/gorm"
+)
+
+type (
+	// SqlTx is a single SQL transaction usable from both APIs.
+	//
+	//	Usage example:
+	//		tx, err := db.BeginSqlTx(ctx, nil)
+	//		if err != nil {
+	//			return err
+	//		}
+	//		defer tx.Rollback()
+	//		tx.Sqlx.ExecContext(ctx, "UPDATE ...")
+	//		tx.Gorm.Create(&model)
+	//		return tx.Commit()
+	SqlTx struct {
+		Sqlx *sqlx.Tx
+		Gorm *gorm.DB
+	}
+
+	// PostgresTx is the former name of SqlTx.
+	//
+	// Deprecated: use SqlTx
+	PostgresTx = SqlTx
+)
+
+// BeginSqlTx begins a transaction on the shared SQL pool
+func (d DBCollection) BeginSqlTx(ctx context.Context, opts *sql.TxOptions) (*SqlTx, error) {
+	if d.SqlDB == nil {
+		return nil, errors.New("the SQL datasource is not enabled")
+	}
+
+	sqlxDB := d.sqlxDB
+	if sqlxDB == nil {
+		sqlxDB = InitializeSqlDatabaseSqlx(d.SqlDB, d.sqlDriver)
+	}
+
+	sqlxTx, err := sqlxDB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error when beginning %s transaction, error: %w", d.sqlDriver, err)
+	}
+
+	tx := &SqlTx{Sqlx: sqlxTx}
+	if d.gormDB != nil {
+		tx.Gorm = tools.GormWithTx(ctx, d.gormDB, sqlxTx.Tx)
+	}
+
+	return tx, nil
+}
+
+// BeginPostgresTx begins a transaction on the shared SQL pool.
+//
+// Deprecated: use BeginSqlTx
+func (d DBCollection) BeginPostgresTx(ctx context.Context, opts *sql.TxOptions) (*SqlTx, error) {
+	return d.BeginSqlTx(ctx, opts)
+}
+
+func (t *SqlTx) Commit() error {
+	return t.Sqlx.Commit()
+}
+
+// Rollback aborts the transaction, it is a no-op once the transaction is committed
+func (t *SqlTx) Rollback() error {
+	if err := t.Sqlx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
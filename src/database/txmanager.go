@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/tools"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Postgres SQLSTATE codes of the transactions that can succeed when retried
+const (
+	SQLSTATE_SERIALIZATION_FAILURE = "40001"
+	SQLSTATE_DEADLOCK_DETECTED     = "40P01"
+)
+
+var txRetryBackoff = tools.Backoff{
+	InitialInterval: 10 * time.Millisecond,
+	MaxInterval:     time.Second,
+}
+
+type (
+	// TxManager runs a unit of work in a transaction carried by the context,
+	// the repositories pick it up through DBCollection.Sqlx, DBCollection.Gorm and the mongo session of the context.
+	//
+	//	Usage example:
+	//		err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+	//			if err := s.orderRepo.Create(ctx, order); err != nil {
+	//				return err
+	//			}
+	//			return s.stockRepo.Decrement(ctx, order.Items)
+	//		})
+	TxManager interface {
+		// WithinTx runs fn in a SQL transaction, committed when fn returns nil and rolled back otherwise.
+		// Nested calls run in a savepoint of the outer transaction, so only their own changes are rolled back on error.
+		// The outermost call is retried on serialization failures and deadlocks.
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+		// WithinTxOptions is WithinTx with the isolation level and read-only mode of opts (ignored by nested calls)
+		WithinTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error
+		// WithinMongoTx runs fn in a mongo session transaction, nested calls join the outer transaction.
+		// The driver retries the transient transaction errors.
+		WithinMongoTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	TxManagerImpl struct {
+		db         DBCollection
+		maxRetries int
+	}
+
+	txContextKey struct{}
+
+	// txState is the SQL transaction of a context, depth counts the nested savepoints
+	txState struct {
+		tx    *SqlTx
+		depth int
+	}
+)
+
+// NewTxManager builds a transaction manager retrying the serialization failures up to maxRetries times
+func NewTxManager(db DBCollection, maxRetries int) TxManager {
+	return &TxManagerImpl{
+		db:         db,
+		maxRetries: maxRetries,
+	}
+}
+
+func (m *TxManagerImpl) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxOptions(ctx, nil, fn)
+}
+
+func (m *TxManagerImpl) WithinTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return m.withinSavepoint(ctx, state, fn)
+	}
+
+	for retry := 0; ; retry++ {
+		err := m.withinNewTx(ctx, opts, fn)
+		if err == nil || !IsRetryableTxError(err) || retry >= m.maxRetries {
+			return err
+		}
+
+		delay := txRetryBackoff.Delay(retry + 1)
+		logrus.WithContext(ctx).Warnf("retrying transaction in %s, error: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (m *TxManagerImpl) withinNewTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.db.BeginSqlTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txContextKey{}, &txState{tx: tx})); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("error when rolling back transaction, error: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error when committing transaction, error: %w", err)
+	}
+	return nil
+}
+
+func (m *TxManagerImpl) withinSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) error {
+	nested := &txState{tx: state.tx, depth: state.depth + 1}
+	savepoint := fmt.Sprintf("sp_%d", nested.depth)
+
+	if _, err := state.tx.Sqlx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return fmt.Errorf("error when creating savepoint %s, error: %w", savepoint, err)
+	}
+
+	var fnErr error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				state.tx.Sqlx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+				panic(p)
+			}
+		}()
+		fnErr = fn(context.WithValue(ctx, txContextKey{}, nested))
+	}()
+
+	if fnErr != nil {
+		if _, err := state.tx.Sqlx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); err != nil {
+			return errors.Join(fnErr, fmt.Errorf("error when rolling back to savepoint %s, error: %w", savepoint, err))
+		}
+		return fnErr
+	}
+
+	if _, err := state.tx.Sqlx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return fmt.Errorf("error when releasing savepoint %s, error: %w", savepoint, err)
+	}
+	return nil
+}
+
+func (m *TxManagerImpl) WithinMongoTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.db.MongoDB == nil {
+		return errors.New("mongodb is not enabled")
+	}
+
+	// mongodb has no savepoint, the nested calls are part of the outer transaction
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := m.db.MongoDB.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("error when starting mongodb session, error: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// IsRetryableTxError reports whether err is a postgres serialization failure or deadlock, with either driver,
+// or a mysql or sqlite lock failure (see tools.IsSqlLockFailure)
+func IsRetryableTxError(err error) bool {
+	var sqlStateErr interface{ SQLState() string }
+	if !errors.As(err, &sqlStateErr) {
+		return tools.IsSqlLockFailure(err)
+	}
+
+	switch sqlStateErr.SQLState() {
+	case SQLSTATE_SERIALIZATION_FAILURE, SQLSTATE_DEADLOCK_DETECTED:
+		return true
+	}
+	return false
+}
+
+// Sqlx returns the sqlx transaction of ctx, or the sqlx pool outside of a transaction
+func (d DBCollection) Sqlx(ctx context.Context) sqlx.ExtContext {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return tools.SqlxWithQueryLog(state.tx.Sqlx, d.queryLog, d.sqlDriver)
+	}
+	return tools.SqlxWithQueryLog(d.sqlxDB, d.queryLog, d.sqlDriver)
+}
+
+// Gorm returns the gorm transaction of ctx, or the gorm pool outside of a transaction, both bound to ctx
+func (d DBCollection) Gorm(ctx context.Context) *gorm.DB {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok && state.tx.Gorm != nil {
+		return state.tx.Gorm.WithContext(ctx)
+	}
+	return d.gormDB.WithContext(ctx)
+}
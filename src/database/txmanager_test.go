@@ -0,0 +1,195 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/databasetest"
+	"reflect"
+	"testing"
+)
+
+// openTestSqlite opens a sqlite DBCollection with an examples table
+func openTestSqlite(t *testing.T) database.DBCollection {
+	t.Helper()
+	db := databasetest.OpenSqlite(t)
+	if _, err := db.SqlDB.ExecContext(context.Background(), "CREATE TABLE examples (name TEXT PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insertExample(name string, db database.DBCollection) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := db.Sqlx(ctx).ExecContext(ctx, "INSERT INTO examples (name) VALUES (?)", name)
+		return err
+	}
+}
+
+func TestTxManagerWithinTx(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		fn      func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "committed",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return insertExample("a", db)
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "rolled back",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					insertExample("a", db)(ctx)
+					return errFailed
+				}
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "failed savepoint rolled back alone",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertExample("a", db)(ctx); err != nil {
+						return err
+					}
+					err := m.WithinTx(ctx, func(ctx context.Context) error {
+						insertExample("b", db)(ctx)
+						return errFailed
+					})
+					if !errors.Is(err, errFailed) {
+						return err
+					}
+					return insertExample("c", db)(ctx)
+				}
+			},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "nested savepoints released",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return m.WithinTx(ctx, func(ctx context.Context) error {
+						if err := insertExample("a", db)(ctx); err != nil {
+							return err
+						}
+						return m.WithinTx(ctx, insertExample("b", db))
+					})
+				}
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "failed savepoint rolls back the outer transaction when returned",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertExample("a", db)(ctx); err != nil {
+						return err
+					}
+					return m.WithinTx(ctx, func(ctx context.Context) error {
+						return errFailed
+					})
+				}
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "gorm and sqlx share the transaction",
+			fn: func(m database.TxManager, db database.DBCollection) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertExample("a", db)(ctx); err != nil {
+						return err
+					}
+					if err := db.Gorm(ctx).Exec("INSERT INTO examples (name) VALUES (?)", "b").Error; err != nil {
+						return err
+					}
+					return errFailed
+				}
+			},
+			wantErr: errFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestSqlite(t)
+			m := database.NewTxManager(db, 0)
+
+			ctx := context.Background()
+			err := m.WithinTx(ctx, tt.fn(m, db))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithinTx() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []string
+			if err := sqlx.SelectContext(ctx, db.Sqlx(ctx), &got, "SELECT name FROM examples ORDER BY name"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("examples = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTxManagerRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+	}{
+		{name: "serialization failure retried", err: sqlStateError(database.SQLSTATE_SERIALIZATION_FAILURE), wantAttempts: 3},
+		{name: "other error not retried", err: errors.New("failed"), wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestSqlite(t)
+			m := database.NewTxManager(db, 2)
+
+			attempts := 0
+			err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("WithinTx() error = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// sqlStateError stands in for the postgres errors of lib/pq and pgx
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsRetryableTxError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "serialization failure", err: sqlStateError(database.SQLSTATE_SERIALIZATION_FAILURE), want: true},
+		{name: "deadlock", err: sqlStateError(database.SQLSTATE_DEADLOCK_DETECTED), want: true},
+		{name: "wrapped deadlock", err: errors.Join(errors.New("context"), sqlStateError(database.SQLSTATE_DEADLOCK_DETECTED)), want: true},
+		{name: "unique violation", err: sqlStateError("23505")},
+		{name: "other error", err: errors.New("failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := database.IsRetryableTxError(tt.err); got != tt.want {
+				t.Errorf("database.IsRetryableTxError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
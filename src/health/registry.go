@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_UP   = "up"
+	STATUS_DOWN = "down"
+
+	defaultCheckTimeout = 2 * time.Second
+)
+
+type (
+	// CheckFunc reports whether a dependency is usable, returning nil when it is healthy
+	CheckFunc func(ctx context.Context) error
+
+	Check struct {
+		Name    string
+		Timeout time.Duration
+		Fn      CheckFunc
+	}
+
+	CheckResult struct {
+		Status  string  `json:"status"`
+		Latency string  `json:"latency"`
+		Error   *string `json:"error,omitempty"`
+	}
+
+	Report struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks"`
+	}
+
+	// Registry holds the readiness checks of every component (datasources, caches, queues, etc.).
+	// New components register their own check, and all of them are executed in parallel.
+	Registry interface {
+		Register(name string, timeout time.Duration, fn CheckFunc)
+		Check(ctx context.Context) Report
+	}
+
+	RegistryImpl struct {
+		mu     sync.RWMutex
+		checks []Check
+	}
+)
+
+func NewRegistry() Registry {
+	return &RegistryImpl{}
+}
+
+// Register adds a named check. A non-positive timeout falls back to the default check timeout.
+func (r *RegistryImpl) Register(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, Check{Name: name, Timeout: timeout, Fn: fn})
+}
+
+// Check runs every registered check in parallel, each bounded by its own timeout
+func (r *RegistryImpl) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]Check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	var (
+		wg      sync.WaitGroup
+		results = make([]CheckResult, len(checks))
+	)
+
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status: STATUS_UP,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+	for i, check := range checks {
+		if results[i].Status != STATUS_UP {
+			report.Status = STATUS_DOWN
+		}
+		report.Checks[check.Name] = results[i]
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.Fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:  STATUS_UP,
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		errMsg := err.Error()
+		result.Status = STATUS_DOWN
+		result.Error = &errMsg
+	}
+
+	return result
+}
@@ -1,14 +1,22 @@
 package controller
 
 import (
+	"github.com/audricimanuel/errorutils"
+	"github.com/go-chi/chi/v5"
 	"go-chi-boilerplate/src/internals/service"
+	"go-chi-boilerplate/src/model"
 	"go-chi-boilerplate/utils/httputils"
 	"net/http"
+	"strconv"
 )
 
 type (
 	ExampleController interface {
 		GetExample(w http.ResponseWriter, r *http.Request)
+		CreateExample(w http.ResponseWriter, r *http.Request)
+		GetExampleByID(w http.ResponseWriter, r *http.Request)
+		UpdateExample(w http.ResponseWriter, r *http.Request)
+		DeleteExample(w http.ResponseWriter, r *http.Request)
 	}
 
 	ExampleControllerImpl struct {
@@ -33,3 +41,134 @@ func (e *ExampleControllerImpl) GetExample(w http.ResponseWriter, r *http.Reques
 	data := e.exampleService.GetExample(r.Context())
 	httputils.MapBaseResponse(w, r, data, nil, nil)
 }
+
+// @Tags			Example
+// @Summary		Create an example
+// @Description	"Creates an example, its version is returned as ETag"
+// @Accept			json
+// @Produce		json
+// @Param			request	body		model.ExampleRequest	true	"example"
+// @Success		200		{object}	httputils.BaseResponse{data=model.Example}
+// @Failure		400		{object}	httputils.BaseResponse
+// @Failure		409		{object}	httputils.BaseResponse
+// @Router			/example [post]
+func (e *ExampleControllerImpl) CreateExample(w http.ResponseWriter, r *http.Request) {
+	var req model.ExampleRequest
+	if err := errorutils.ValidatePayload(r, &req); err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+
+	example, err := e.exampleService.CreateExample(r.Context(), req)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, httputils.ToHttpError(err), nil)
+		return
+	}
+	httputils.SetETag(w, example.Version)
+	httputils.MapBaseResponse(w, r, example, nil, nil)
+}
+
+// @Tags			Example
+// @Summary		Get an example
+// @Description	"Returns an example, its version is returned as ETag"
+// @Produce		json
+// @Param			id	path		int	true	"example id"
+// @Success		200	{object}	httputils.BaseResponse{data=model.Example}
+// @Failure		400	{object}	httputils.BaseResponse
+// @Failure		404	{object}	httputils.BaseResponse
+// @Router			/example/{id} [get]
+func (e *ExampleControllerImpl) GetExampleByID(w http.ResponseWriter, r *http.Request) {
+	id, err := exampleID(r)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+
+	example, getErr := e.exampleService.GetExampleByID(r.Context(), id)
+	if getErr != nil {
+		httputils.MapBaseResponse(w, r, nil, httputils.ToHttpError(getErr), nil)
+		return
+	}
+	httputils.SetETag(w, example.Version)
+	httputils.MapBaseResponse(w, r, example, nil, nil)
+}
+
+// @Tags			Example
+// @Summary		Update an example
+// @Description	"Updates an example at the version sent with If-Match (the ETag it was read with), the new version is returned as ETag"
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int						true	"example id"
+// @Param			If-Match	header		string					true	"version of the example, e.g. \"3\""
+// @Param			request		body		model.ExampleRequest	true	"example"
+// @Success		200			{object}	httputils.BaseResponse{data=model.Example}
+// @Failure		400			{object}	httputils.BaseResponse
+// @Failure		404			{object}	httputils.BaseResponse
+// @Failure		409			{object}	httputils.BaseResponse
+// @Failure		428			{object}	httputils.BaseResponse
+// @Router			/example/{id} [put]
+func (e *ExampleControllerImpl) UpdateExample(w http.ResponseWriter, r *http.Request) {
+	id, err := exampleID(r)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+	version, err := httputils.RequireIfMatch(r)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+	var req model.ExampleRequest
+	if err := errorutils.ValidatePayload(r, &req); err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+
+	example, updateErr := e.exampleService.UpdateExample(r.Context(), id, version, req)
+	if updateErr != nil {
+		httputils.MapBaseResponse(w, r, nil, httputils.ToHttpError(updateErr), nil)
+		return
+	}
+	httputils.SetETag(w, example.Version)
+	httputils.MapBaseResponse(w, r, example, nil, nil)
+}
+
+// @Tags			Example
+// @Summary		Delete an example
+// @Description	"Deletes an example at the version sent with If-Match (the ETag it was read with)"
+// @Produce		json
+// @Param			id			path		int		true	"example id"
+// @Param			If-Match	header		string	true	"version of the example, e.g. \"3\""
+// @Success		200			{object}	httputils.BaseResponse
+// @Failure		400			{object}	httputils.BaseResponse
+// @Failure		404			{object}	httputils.BaseResponse
+// @Failure		409			{object}	httputils.BaseResponse
+// @Failure		428			{object}	httputils.BaseResponse
+// @Router			/example/{id} [delete]
+func (e *ExampleControllerImpl) DeleteExample(w http.ResponseWriter, r *http.Request) {
+	id, err := exampleID(r)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+	version, err := httputils.RequireIfMatch(r)
+	if err != nil {
+		httputils.MapBaseResponse(w, r, nil, err, nil)
+		return
+	}
+
+	if deleteErr := e.exampleService.DeleteExample(r.Context(), id, version); deleteErr != nil {
+		httputils.MapBaseResponse(w, r, nil, httputils.ToHttpError(deleteErr), nil)
+		return
+	}
+	httputils.MapBaseResponse(w, r, nil, nil, nil)
+}
+
+// exampleID reads the id path parameter, errorutils.ErrorBadRequest when it is not a number
+func exampleID(r *http.Request) (int64, errorutils.HttpError) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, errorutils.ErrorBadRequest
+	}
+	return id, nil
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"go-chi-boilerplate/src/model"
+	"go-chi-boilerplate/utils/httputils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeExampleService keeps a single example at version 2
+type fakeExampleService struct{}
+
+func (fakeExampleService) GetExample(ctx context.Context) model.ExampleResponse {
+	return model.ExampleResponse{}
+}
+
+func (fakeExampleService) CreateExample(ctx context.Context, req model.ExampleRequest) (model.Example, error) {
+	return model.Example{ID: 1, Name: req.Name, Versioning: model.Versioning{Version: 1}}, nil
+}
+
+func (fakeExampleService) GetExampleByID(ctx context.Context, id int64) (model.Example, error) {
+	return model.Example{ID: id, Versioning: model.Versioning{Version: 2}}, nil
+}
+
+func (fakeExampleService) UpdateExample(ctx context.Context, id int64, version *int64, req model.ExampleRequest) (model.Example, error) {
+	if version != nil && *version != 2 {
+		return model.Example{}, httputils.ErrorVersionConflict
+	}
+	return model.Example{ID: id, Name: req.Name, Versioning: model.Versioning{Version: 3}}, nil
+}
+
+func (fakeExampleService) DeleteExample(ctx context.Context, id int64, version *int64) error {
+	if version != nil && *version != 2 {
+		return httputils.ErrorVersionConflict
+	}
+	return nil
+}
+
+func TestExampleControllerIfMatch(t *testing.T) {
+	controller := NewExampleController(fakeExampleService{})
+	r := chi.NewRouter()
+	r.Get("/example/{id}", controller.GetExampleByID)
+	r.Put("/example/{id}", controller.UpdateExample)
+	r.Delete("/example/{id}", controller.DeleteExample)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		ifMatch  string
+		want     int
+		wantETag string
+	}{
+		{name: "get", method: http.MethodGet, path: "/example/1", want: http.StatusOK, wantETag: `"2"`},
+		{name: "get malformed id", method: http.MethodGet, path: "/example/abc", want: http.StatusBadRequest},
+		{name: "update", method: http.MethodPut, path: "/example/1", ifMatch: `"2"`, want: http.StatusOK, wantETag: `"3"`},
+		{name: "update any version", method: http.MethodPut, path: "/example/1", ifMatch: "*", want: http.StatusOK, wantETag: `"3"`},
+		{name: "update conflict", method: http.MethodPut, path: "/example/1", ifMatch: `"1"`, want: http.StatusConflict},
+		{name: "update without If-Match", method: http.MethodPut, path: "/example/1", want: http.StatusPreconditionRequired},
+		{name: "update malformed If-Match", method: http.MethodPut, path: "/example/1", ifMatch: "two", want: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, path: "/example/1", ifMatch: `W/"2"`, want: http.StatusOK},
+		{name: "delete conflict", method: http.MethodDelete, path: "/example/1", ifMatch: `"1"`, want: http.StatusConflict},
+		{name: "delete without If-Match", method: http.MethodDelete, path: "/example/1", want: http.StatusPreconditionRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"example"}`))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+			if etag := rec.Header().Get("ETag"); etag != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", etag, tt.wantETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"github.com/audricimanuel/errorutils"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/health"
+	"go-chi-boilerplate/utils/httputils"
+	"net/http"
+)
+
+type (
+	HealthController interface {
+		Liveness(w http.ResponseWriter, r *http.Request)
+		Readiness(w http.ResponseWriter, r *http.Request)
+		DBPools(w http.ResponseWriter, r *http.Request)
+	}
+
+	// PoolStatsProvider returns the latest statistics of the datasource pools
+	PoolStatsProvider interface {
+		Latest() []database.PoolStats
+	}
+
+	HealthControllerImpl struct {
+		registry  health.Registry
+		poolStats PoolStatsProvider
+	}
+)
+
+func NewHealthController(registry health.Registry, poolStats PoolStatsProvider) HealthController {
+	return &HealthControllerImpl{
+		registry:  registry,
+		poolStats: poolStats,
+	}
+}
+
+// @Tags			Health
+// @Summary		Liveness probe
+// @Description	"Reports whether the process is alive"
+// @Produce		json
+// @Success		200	{object}	httputils.BaseResponse
+// @Router			/healthz [get]
+func (h *HealthControllerImpl) Liveness(w http.ResponseWriter, r *http.Request) {
+	httputils.MapBaseResponse(w, r, map[string]string{"status": health.STATUS_UP}, nil, nil)
+}
+
+// @Tags			Health
+// @Summary		Readiness probe
+// @Description	"Reports the health of every registered dependency"
+// @Produce		json
+// @Success		200	{object}	httputils.BaseResponse
+// @Failure		503	{object}	httputils.BaseResponse
+// @Router			/readyz [get]
+func (h *HealthControllerImpl) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.registry.Check(r.Context())
+
+	var err errorutils.HttpError
+	if report.Status != health.STATUS_UP {
+		err = errorutils.ErrorServiceNotAvailable
+	}
+
+	httputils.MapBaseResponse(w, r, report, err, nil)
+}
+
+// @Tags			Health
+// @Summary		Datasource pool statistics
+// @Description	"Reports the open, in-use and idle connections and the wait statistics of every datasource pool"
+// @Produce		json
+// @Success		200	{object}	httputils.BaseResponse
+// @Router			/debug/db-pools [get]
+func (h *HealthControllerImpl) DBPools(w http.ResponseWriter, r *http.Request) {
+	httputils.MapBaseResponse(w, r, h.poolStats.Latest(), nil, nil)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"go-chi-boilerplate/src/database/mongoschema"
+)
+
+// CollectionSpecs returns the mongodb collections declared by the repositories,
+// reconciled at startup (MONGODB_SCHEMA_RECONCILE) or by cmd/mongoschema
+func CollectionSpecs() []mongoschema.CollectionSpec {
+	return []mongoschema.CollectionSpec{
+		exampleCollection,
+	}
+}
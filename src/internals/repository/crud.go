@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/audricimanuel/errorutils"
+	"github.com/jmoiron/sqlx"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/model"
+	"go-chi-boilerplate/src/tools"
+	"go-chi-boilerplate/utils/httputils"
+	"go-chi-boilerplate/utils/paramquery"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options of the crud tag, the columns are named by the db tag
+//
+//	Usage example:
+//		ID        int64     `db:"id" crud:"pk"`
+//		CreatedAt time.Time `db:"created_at" crud:"readonly"`
+const (
+	TAG_CRUD         = "crud"
+	CRUD_PK          = "pk"         // primary key, generated by the database
+	CRUD_READONLY    = "readonly"   // set by the database (defaults, triggers), never written
+	CRUD_CREATE_ONLY = "createonly" // written by Create only (e.g. created_at)
+	CRUD_SOFT_DELETE = "softdelete" // deletion time, Delete sets it instead of deleting the row (see model.Audit)
+	CRUD_VERSION     = "version"    // optimistic lock, checked and incremented by Update and Delete (see model.Versioning)
+
+	sqlStateUniqueViolation = "23505"
+
+	// audit columns of model.Audit, touched by Delete
+	columnUpdatedAt = "updated_at"
+	columnUpdatedBy = "updated_by"
+)
+
+type (
+	// CRUDConfig configures a CRUD repository, the columns are read from the struct tags of T
+	CRUDConfig struct {
+		Table string
+		// SearchColumns are matched (case-insensitive, substring) against the keyword of List and Count
+		SearchColumns []string
+		// OrderBy is the ORDER BY clause of List, defaults to the primary key
+		OrderBy string
+	}
+
+	// CRUD is a typed repository over SqlDBSqlx, reads go through SqlxRead and writes through Sqlx,
+	// so both take part in the transaction of the context.
+	// Models implementing model.Auditable are touched before being written, and the soft deleted rows
+	// are left out of the reads unless the context comes from WithDeleted.
+	// Models with a version column fail to be updated or deleted with httputils.ErrorVersionConflict
+	// when they are not at the version of the entity (or the one of WithExpectedVersion).
+	// Written rows are read back with RETURNING, or by primary key with mysql which lacks it.
+	//
+	//	Usage example:
+	//		examples := repository.NewCRUD[model.Example](db, repository.CRUDConfig{Table: "examples", SearchColumns: []string{"name"}})
+	//		data, total, err := examples.List(ctx, paramquery.SetBaseParamQuery(ctx))
+	//		meta := httputils.SetBaseMeta(params.Page, params.Limit, total)
+	CRUD[T any] interface {
+		// Get returns errorutils.ErrorNotFound when no row has the id
+		Get(ctx context.Context, id interface{}) (T, error)
+		// List returns a page of rows and the total of rows matching the keyword
+		List(ctx context.Context, params paramquery.BaseParamQuery) ([]T, int, error)
+		// Create inserts entity and reads back the columns set by the database, returning errorutils.ErrorDuplicateData on a unique violation
+		Create(ctx context.Context, entity *T) error
+		// Update writes every column of entity but the primary key, the readonly and the createonly ones,
+		// returning errorutils.ErrorNotFound when no live row (any row with WithDeleted) has its id
+		Update(ctx context.Context, entity *T) error
+		// Delete soft deletes the row when T has a softdelete column, incrementing its version and touching its updated columns
+		// (so updated_by is the actor who deleted it), returning errorutils.ErrorNotFound when no live row has the id
+		Delete(ctx context.Context, id interface{}) error
+		// Purge deletes the row for good, soft deleted or not, returning errorutils.ErrorNotFound when no row has the id
+		Purge(ctx context.Context, id interface{}) error
+		Count(ctx context.Context, params paramquery.BaseParamQuery) (int, error)
+	}
+
+	CRUDImpl[T any] struct {
+		db     database.DBCollection
+		config CRUDConfig
+		crudSchema
+	}
+
+	// crudSchema is the columns of a model read from its tags, with the index of the pk and version fields
+	crudSchema struct {
+		pk           string
+		pkIndex      []int
+		columns      []string
+		insertable   []string
+		updatable    []string
+		softDelete   string
+		version      string
+		versionIndex []int
+		// auditable is set when T implements model.Auditable, with the updated_at and updated_by columns
+		auditable bool
+	}
+)
+
+// NewCRUD builds a CRUD repository of T, panicking when T has no primary key (a programming error)
+func NewCRUD[T any](db database.DBCollection, config CRUDConfig) CRUD[T] {
+	var entity T
+	var schema crudSchema
+	schema.read(reflect.TypeOf(entity), nil)
+	if schema.pk == "" {
+		panic(fmt.Sprintf("repository.NewCRUD: %T has no field tagged crud:\"pk\"", entity))
+	}
+	if _, ok := any(&entity).(model.Auditable); ok {
+		schema.auditable = slices.Contains(schema.columns, columnUpdatedAt) && slices.Contains(schema.columns, columnUpdatedBy)
+	}
+	if config.OrderBy == "" {
+		config.OrderBy = schema.pk
+	}
+
+	return &CRUDImpl[T]{
+		db:         db,
+		config:     config,
+		crudSchema: schema,
+	}
+}
+
+func (c *CRUDImpl[T]) Get(ctx context.Context, id interface{}) (T, error) {
+	var entity T
+
+	db := c.db.SqlxRead(ctx)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?%s", strings.Join(c.columns, ", "), c.config.Table, c.pk, c.live(ctx, " AND "))
+	if err := sqlx.GetContext(ctx, db, &entity, db.Rebind(query), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity, errorutils.ErrorNotFound
+		}
+		return entity, fmt.Errorf("error when getting %s %v, error: %w", c.config.Table, id, err)
+	}
+
+	return entity, nil
+}
+
+func (c *CRUDImpl[T]) List(ctx context.Context, params paramquery.BaseParamQuery) ([]T, int, error) {
+	total, err := c.Count(ctx, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, args := c.where(ctx, params)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", strings.Join(c.columns, ", "), c.config.Table, where, c.config.OrderBy)
+	if params.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, params.Limit, params.Offset)
+	}
+
+	db := c.db.SqlxRead(ctx)
+	data := make([]T, 0)
+	if err := sqlx.SelectContext(ctx, db, &data, db.Rebind(query), args...); err != nil {
+		return nil, 0, fmt.Errorf("error when listing %s, error: %w", c.config.Table, err)
+	}
+
+	return data, total, nil
+}
+
+func (c *CRUDImpl[T]) Count(ctx context.Context, params paramquery.BaseParamQuery) (int, error) {
+	where, args := c.where(ctx, params)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", c.config.Table, where)
+
+	var total int
+	db := c.db.SqlxRead(ctx)
+	if err := sqlx.GetContext(ctx, db, &total, db.Rebind(query), args...); err != nil {
+		return 0, fmt.Errorf("error when counting %s, error: %w", c.config.Table, err)
+	}
+
+	return total, nil
+}
+
+func (c *CRUDImpl[T]) Create(ctx context.Context, entity *T) error {
+	if auditable, ok := any(entity).(model.Auditable); ok {
+		auditable.TouchCreated(ctx)
+	}
+
+	placeholders := make([]string, len(c.insertable))
+	for i, column := range c.insertable {
+		placeholders[i] = ":" + column
+		if column == c.version {
+			placeholders[i] = "1"
+		}
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		c.config.Table, strings.Join(c.insertable, ", "), strings.Join(placeholders, ", "),
+	)
+
+	if err := c.namedWrite(ctx, entity, query); err != nil {
+		if isUniqueViolation(err) {
+			return errorutils.ErrorDuplicateData
+		}
+		return fmt.Errorf("error when creating %s, error: %w", c.config.Table, err)
+	}
+
+	return nil
+}
+
+func (c *CRUDImpl[T]) Update(ctx context.Context, entity *T) error {
+	if auditable, ok := any(entity).(model.Auditable); ok {
+		auditable.TouchUpdated(ctx)
+	}
+
+	assignments := make([]string, len(c.updatable))
+	for i, column := range c.updatable {
+		assignments[i] = fmt.Sprintf("%s = :%s", column, column)
+	}
+	where := fmt.Sprintf("%s = :%s%s", c.pk, c.pk, c.live(ctx, " AND "))
+	if c.version != "" {
+		if version, ok := expectedVersion(ctx); ok {
+			reflect.ValueOf(entity).Elem().FieldByIndex(c.versionIndex).SetInt(version)
+		}
+		assignments = append(assignments, fmt.Sprintf("%s = %s + 1", c.version, c.version))
+		where += fmt.Sprintf(" AND %s = :%s", c.version, c.version)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.config.Table, strings.Join(assignments, ", "), where)
+
+	if err := c.namedWrite(ctx, entity, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.notFoundOrConflict(ctx, reflect.ValueOf(entity).Elem().FieldByIndex(c.pkIndex).Interface())
+		}
+		if isUniqueViolation(err) {
+			return errorutils.ErrorDuplicateData
+		}
+		return fmt.Errorf("error when updating %s, error: %w", c.config.Table, err)
+	}
+
+	return nil
+}
+
+func (c *CRUDImpl[T]) Delete(ctx context.Context, id interface{}) error {
+	if c.softDelete == "" {
+		return c.Purge(ctx, id)
+	}
+
+	now := time.Now()
+	assignments := []string{fmt.Sprintf("%s = ?", c.softDelete)}
+	args := []interface{}{now}
+	if c.auditable {
+		assignments = append(assignments, fmt.Sprintf("%s = ?", columnUpdatedAt), fmt.Sprintf("%s = ?", columnUpdatedBy))
+		args = append(args, now, tools.Actor(ctx))
+	}
+	if c.version != "" {
+		assignments = append(assignments, fmt.Sprintf("%s = %s + 1", c.version, c.version))
+	}
+
+	where := fmt.Sprintf("%s = ? AND %s IS NULL", c.pk, c.softDelete)
+	args = append(args, id)
+	if version, ok := expectedVersion(ctx); ok && c.version != "" {
+		where += fmt.Sprintf(" AND %s = ?", c.version)
+		args = append(args, version)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.config.Table, strings.Join(assignments, ", "), where)
+	return c.exec(ctx, "deleting", id, query, args...)
+}
+
+func (c *CRUDImpl[T]) Purge(ctx context.Context, id interface{}) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", c.config.Table, c.pk)
+	args := []interface{}{id}
+	if version, ok := expectedVersion(ctx); ok && c.version != "" {
+		query += fmt.Sprintf(" AND %s = ?", c.version)
+		args = append(args, version)
+	}
+
+	// the soft deleted rows are purged too, so they are not reported missing
+	return c.exec(WithDeleted(ctx), "purging", id, query, args...)
+}
+
+// exec runs a statement affecting the row of id, see notFoundOrConflict when it affects none
+func (c *CRUDImpl[T]) exec(ctx context.Context, action string, id interface{}, query string, args ...interface{}) error {
+	db := c.db.Sqlx(ctx)
+
+	result, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("error when %s %s %v, error: %w", action, c.config.Table, id, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return c.notFoundOrConflict(ctx, id)
+	}
+
+	return nil
+}
+
+// notFoundOrConflict explains why a write matched no row: httputils.ErrorVersionConflict when the row of id
+// is there (so at another version), errorutils.ErrorNotFound otherwise
+func (c *CRUDImpl[T]) notFoundOrConflict(ctx context.Context, id interface{}) error {
+	if c.version == "" {
+		return errorutils.ErrorNotFound
+	}
+
+	var count int
+	db := c.db.Sqlx(ctx)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?%s", c.config.Table, c.pk, c.live(ctx, " AND "))
+	if err := sqlx.GetContext(ctx, db, &count, db.Rebind(query), id); err != nil {
+		return fmt.Errorf("error when checking %s %v, error: %w", c.config.Table, id, err)
+	}
+	if count > 0 {
+		return httputils.ErrorVersionConflict
+	}
+
+	return errorutils.ErrorNotFound
+}
+
+// namedWrite runs an INSERT or UPDATE with :column placeholders bound to entity, scanning the written row back into it.
+// It returns sql.ErrNoRows when the statement writes no row.
+func (c *CRUDImpl[T]) namedWrite(ctx context.Context, entity *T, query string) error {
+	db := c.db.Sqlx(ctx)
+	if tools.SqlDialect(db.DriverName()) != tools.DIALECT_MYSQL {
+		query, args, err := db.BindNamed(query+" RETURNING "+strings.Join(c.columns, ", "), entity)
+		if err != nil {
+			return err
+		}
+		return sqlx.GetContext(ctx, db, entity, query, args...)
+	}
+
+	query, args, err := db.BindNamed(query, entity)
+	if err != nil {
+		return err
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// the id generated by an insert, 0 for an update
+	pk := reflect.ValueOf(entity).Elem().FieldByIndex(c.pkIndex)
+	if id, err := result.LastInsertId(); err == nil && id != 0 && pk.CanInt() {
+		pk.SetInt(id)
+	}
+
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", strings.Join(c.columns, ", "), c.config.Table, c.pk)
+	return sqlx.GetContext(ctx, db, entity, db.Rebind(query), pk.Interface())
+}
+
+// where matches the keyword against the search columns, leaving out the soft deleted rows
+func (c *CRUDImpl[T]) where(ctx context.Context, params paramquery.BaseParamQuery) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if params.Keyword != nil && *params.Keyword != "" && len(c.config.SearchColumns) > 0 {
+		var (
+			matches = make([]string, len(c.config.SearchColumns))
+			pattern = "%" + strings.ToLower(*params.Keyword) + "%"
+		)
+		for i, column := range c.config.SearchColumns {
+			matches[i] = fmt.Sprintf("LOWER(%s) LIKE ?", column)
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(matches, " OR ")))
+	}
+	if live := c.live(ctx, ""); live != "" {
+		conditions = append(conditions, live)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// live returns the condition leaving out the soft deleted rows prefixed by prefix, empty when T has no softdelete column
+// or ctx comes from WithDeleted
+func (c *CRUDImpl[T]) live(ctx context.Context, prefix string) string {
+	if c.softDelete == "" || withDeleted(ctx) {
+		return ""
+	}
+	return fmt.Sprintf("%s%s IS NULL", prefix, c.softDelete)
+}
+
+// read reads the columns of t from its db tags, flattening the embedded structs (index is the one of t)
+func (s *crudSchema) read(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			s.read(field.Type, fieldIndex)
+			continue
+		}
+
+		column := strings.Split(field.Tag.Get("db"), ",")[0]
+		if column == "" || column == "-" || !field.IsExported() {
+			continue
+		}
+		s.columns = append(s.columns, column)
+
+		switch field.Tag.Get(TAG_CRUD) {
+		case CRUD_PK:
+			if s.pk == "" {
+				s.pk, s.pkIndex = column, fieldIndex
+			}
+		case CRUD_VERSION:
+			s.version, s.versionIndex = column, fieldIndex
+			s.insertable = append(s.insertable, column)
+		case CRUD_READONLY:
+		case CRUD_CREATE_ONLY:
+			s.insertable = append(s.insertable, column)
+		case CRUD_SOFT_DELETE:
+			s.softDelete = column
+		default:
+			s.insertable = append(s.insertable, column)
+			s.updatable = append(s.updatable, column)
+		}
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	var sqlStateErr interface{ SQLState() string }
+	if errors.As(err, &sqlStateErr) {
+		return sqlStateErr.SQLState() == sqlStateUniqueViolation
+	}
+	return tools.IsSqlUniqueViolation(err)
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/audricimanuel/errorutils"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/databasetest"
+	"go-chi-boilerplate/src/database/migration"
+	"go-chi-boilerplate/src/model"
+	"go-chi-boilerplate/src/tools"
+	"go-chi-boilerplate/utils/httputils"
+	"go-chi-boilerplate/utils/paramquery"
+	"testing"
+)
+
+// openTestSqlite opens a sqlite DBCollection migrated with the embedded migrations
+func openTestSqlite(t *testing.T) database.DBCollection {
+	t.Helper()
+	db := databasetest.OpenSqlite(t)
+	if err := migration.NewMigrator(db.SqlDB, "sqlite", migration.Source(""), "schema_migrations").Up(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestExamples(t *testing.T) CRUD[model.Example] {
+	t.Helper()
+	return NewCRUD[model.Example](openTestSqlite(t), CRUDConfig{Table: "examples", SearchColumns: []string{"name"}})
+}
+
+func TestCRUDWrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error
+		wantErr error
+	}{
+		{
+			name: "get missing",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				_, err := examples.Get(ctx, example.ID+1)
+				return err
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "update",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				example.Name = "b"
+				return examples.Update(ctx, example)
+			},
+		},
+		{
+			name: "update at the expected version",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				return examples.Update(WithExpectedVersion(ctx, 1), example)
+			},
+		},
+		{
+			name: "update at a stale version",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				return examples.Update(WithExpectedVersion(ctx, 2), example)
+			},
+			wantErr: httputils.ErrorVersionConflict,
+		},
+		{
+			name: "update missing",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				example.ID++
+				return examples.Update(ctx, example)
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "update soft deleted",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				return examples.Update(ctx, example)
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "get soft deleted",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				_, err := examples.Get(ctx, example.ID)
+				return err
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "get soft deleted with deleted",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				deleted, err := examples.Get(WithDeleted(ctx), example.ID)
+				if err == nil && !deleted.DeletedAt.Valid {
+					return errors.New("deleted_at not set")
+				}
+				return err
+			},
+		},
+		{
+			name: "delete twice",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				return examples.Delete(ctx, example.ID)
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "delete at a stale version",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				return examples.Delete(WithExpectedVersion(ctx, 2), example.ID)
+			},
+			wantErr: httputils.ErrorVersionConflict,
+		},
+		{
+			name: "purge soft deleted at a stale version",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				return examples.Purge(WithExpectedVersion(ctx, 1), example.ID)
+			},
+			wantErr: httputils.ErrorVersionConflict,
+		},
+		{
+			name: "purge missing at a version",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				return examples.Purge(WithExpectedVersion(ctx, 1), example.ID+1)
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+		{
+			name: "purge soft deleted",
+			run: func(ctx context.Context, examples CRUD[model.Example], example *model.Example) error {
+				if err := examples.Delete(ctx, example.ID); err != nil {
+					return err
+				}
+				if err := examples.Purge(ctx, example.ID); err != nil {
+					return err
+				}
+				_, err := examples.Get(WithDeleted(ctx), example.ID)
+				return err
+			},
+			wantErr: errorutils.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tools.WithActor(context.Background(), "tester")
+			examples := newTestExamples(t)
+
+			example := model.Example{Name: "a"}
+			if err := examples.Create(ctx, &example); err != nil {
+				t.Fatal(err)
+			}
+			if example.ID == 0 || example.Version != 1 || example.CreatedBy != "tester" {
+				t.Fatalf("Create() = %+v, want an id, version 1 and created_by tester", example)
+			}
+
+			if err := tt.run(ctx, examples, &example); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCRUDUpdateReadsBack(t *testing.T) {
+	ctx := context.Background()
+	examples := newTestExamples(t)
+
+	example := model.Example{Name: "a"}
+	if err := examples.Create(tools.WithActor(ctx, "creator"), &example); err != nil {
+		t.Fatal(err)
+	}
+	example.Name = "b"
+	if err := examples.Update(tools.WithActor(ctx, "updater"), &example); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := examples.Get(ctx, example.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "b" || got.Version != 2 || got.CreatedBy != "creator" || got.UpdatedBy != "updater" {
+		t.Errorf("Get() = %+v, want name b, version 2, created by creator and updated by updater", got)
+	}
+	if example.Version != 2 {
+		t.Errorf("Update() version = %d, want 2", example.Version)
+	}
+}
+
+func TestCRUDDeleteTouches(t *testing.T) {
+	ctx := context.Background()
+	examples := newTestExamples(t)
+
+	example := model.Example{Name: "a"}
+	if err := examples.Create(tools.WithActor(ctx, "creator"), &example); err != nil {
+		t.Fatal(err)
+	}
+	if err := examples.Delete(tools.WithActor(ctx, "deleter"), example.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := examples.Get(WithDeleted(ctx), example.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.DeletedAt.Valid || got.Version != 2 || got.CreatedBy != "creator" || got.UpdatedBy != "deleter" {
+		t.Errorf("Get() = %+v, want deleted, version 2, created by creator and updated by deleter", got)
+	}
+	if got.UpdatedAt.Before(example.UpdatedAt) {
+		t.Errorf("updated_at = %v, want after %v", got.UpdatedAt, example.UpdatedAt)
+	}
+}
+
+func TestCRUDDuplicate(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSqlite(t)
+	if _, err := db.SqlDB.ExecContext(ctx, "CREATE UNIQUE INDEX idx_examples_name ON examples (name)"); err != nil {
+		t.Fatal(err)
+	}
+	examples := NewCRUD[model.Example](db, CRUDConfig{Table: "examples"})
+
+	for _, name := range []string{"a", "b"} {
+		if err := examples.Create(ctx, &model.Example{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := examples.Create(ctx, &model.Example{Name: "a"}); !errors.Is(err, errorutils.ErrorDuplicateData) {
+		t.Errorf("Create() error = %v, want %v", err, errorutils.ErrorDuplicateData)
+	}
+	b := model.Example{Name: "a"}
+	b.ID = 2
+	b.Version = 1
+	if err := examples.Update(ctx, &b); !errors.Is(err, errorutils.ErrorDuplicateData) {
+		t.Errorf("Update() error = %v, want %v", err, errorutils.ErrorDuplicateData)
+	}
+}
+
+func TestCRUDList(t *testing.T) {
+	ctx := context.Background()
+	examples := newTestExamples(t)
+
+	for _, name := range []string{"Apple", "banana", "apricot", "cherry"} {
+		if err := examples.Create(ctx, &model.Example{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cherry, err := examples.Get(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := examples.Delete(ctx, cherry.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	keyword := func(s string) *string { return &s }
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		params    paramquery.BaseParamQuery
+		wantNames []string
+		wantTotal int
+	}{
+		{name: "all live", ctx: ctx, wantNames: []string{"Apple", "banana", "apricot"}, wantTotal: 3},
+		{name: "with deleted", ctx: WithDeleted(ctx), wantNames: []string{"Apple", "banana", "apricot", "cherry"}, wantTotal: 4},
+		{name: "keyword ignores case", ctx: ctx, params: paramquery.BaseParamQuery{Keyword: keyword("AP")}, wantNames: []string{"Apple", "apricot"}, wantTotal: 2},
+		{name: "keyword of a deleted row", ctx: ctx, params: paramquery.BaseParamQuery{Keyword: keyword("cherry")}, wantNames: []string{}, wantTotal: 0},
+		{name: "page", ctx: ctx, params: paramquery.BaseParamQuery{Limit: 2, Offset: 2}, wantNames: []string{"apricot"}, wantTotal: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, total, err := examples.List(tt.ctx, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(data))
+			for i, example := range data {
+				names[i] = example.Name
+			}
+			if total != tt.wantTotal || len(names) != len(tt.wantNames) {
+				t.Fatalf("List() = %v, %d, want %v, %d", names, total, tt.wantNames, tt.wantTotal)
+			}
+			for i := range names {
+				if names[i] != tt.wantNames[i] {
+					t.Fatalf("List() = %v, want %v", names, tt.wantNames)
+				}
+			}
+		})
+	}
+}
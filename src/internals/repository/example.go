@@ -4,21 +4,48 @@ import (
 	"context"
 	"github.com/sirupsen/logrus"
 	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/database/mongoschema"
 	"go-chi-boilerplate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
+var exampleCollection = mongoschema.CollectionSpec{
+	Name: "examples",
+	Validator: bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": bson.A{"name"},
+			"properties": bson.M{
+				"name": bson.M{"bsonType": "string"},
+			},
+		},
+	},
+	Indexes: []mongoschema.IndexSpec{
+		// unique among the live documents, a soft deleted one keeps its name without blocking it
+		{Keys: bson.D{{Key: "name", Value: 1}}, Unique: true, PartialFilter: bson.M{FIELD_DELETED_AT: bson.M{"$type": "null"}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	},
+}
+
 type (
 	ExampleRepository interface {
+		CRUD[model.Example]
 		GetExample(ctx context.Context) model.ExampleResponse
 	}
 
 	ExampleRepositoryImpl struct {
+		CRUD[model.Example]
 		db database.DBCollection
 	}
 )
 
 func NewExampleRepository(db database.DBCollection) ExampleRepository {
 	return &ExampleRepositoryImpl{
+		CRUD: NewCRUD[model.Example](db, CRUDConfig{
+			Table:         "examples",
+			SearchColumns: []string{"name"},
+			OrderBy:       "created_at DESC",
+		}),
 		db: db,
 	}
 }
@@ -0,0 +1,483 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/audricimanuel/errorutils"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/model"
+	"go-chi-boilerplate/src/tools"
+	"go-chi-boilerplate/utils/httputils"
+	"go-chi-boilerplate/utils/paramquery"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Audit fields of the documents, see model.MongoAudit
+const (
+	FIELD_CREATED_AT = "created_at"
+	FIELD_CREATED_BY = "created_by"
+	FIELD_UPDATED_AT = "updated_at"
+	FIELD_UPDATED_BY = "updated_by"
+	FIELD_DELETED_AT = "deleted_at"
+	FIELD_VERSION    = "version" // see model.Versioning
+)
+
+type (
+	// MongoCollectionConfig configures a MongoCollection, the documents are mapped by the bson tags of T
+	MongoCollectionConfig struct {
+		Collection string
+		// SearchFields are matched (case-insensitive regex) against the keyword of Find and Aggregate
+		SearchFields []string
+		// TextSearch matches the keyword with $text instead of SearchFields, the collection needs a text index
+		TextSearch bool
+		// Sort of Find, defaults to _id
+		Sort bson.D
+		// SoftDelete makes Delete set deleted_at instead of deleting the document (see model.MongoAudit),
+		// the soft deleted documents are left out unless the context comes from WithDeleted
+		SoftDelete bool
+	}
+
+	// MongoCollection is a typed repository over a MongoDB collection. Operations run in the session of the context,
+	// so they take part in TxManager.WithinMongoTx. String ids holding an ObjectID hex are converted.
+	// Documents implementing model.Auditable are touched before being written, Update sets their updated fields.
+	// Documents implementing model.Versioned get their version incremented by Update and Delete, which fail with
+	// httputils.ErrorVersionConflict when the context comes from WithExpectedVersion and the document is at another version.
+	//
+	//	Usage example:
+	//		examples := repository.NewMongoCollection[model.Example](db, repository.MongoCollectionConfig{Collection: "examples", SearchFields: []string{"name"}})
+	//		data, total, err := examples.Find(ctx, bson.M{"active": true}, paramquery.SetBaseParamQuery(ctx))
+	//		meta := httputils.SetBaseMeta(params.Page, params.Limit, total)
+	MongoCollection[T any] interface {
+		// FindByID returns errorutils.ErrorNotFound when no document has the id
+		FindByID(ctx context.Context, id interface{}) (T, error)
+		// Find returns a page of the documents matching filter (nil for all) and the keyword, and their total
+		Find(ctx context.Context, filter interface{}, params paramquery.BaseParamQuery) ([]T, int, error)
+		// Insert returns the id of the inserted document, or errorutils.ErrorDuplicateData on a duplicate key
+		Insert(ctx context.Context, entity *T) (interface{}, error)
+		// Update applies update (e.g. bson.M{"$set": ...}), returning errorutils.ErrorNotFound when no document has the id
+		Update(ctx context.Context, id interface{}, update interface{}) error
+		// Upsert sets the fields of entity on the document matching filter, inserting it when there is none (no version check).
+		// The creation audit fields and _id are only written on insert, the version starts at 1 and is incremented on update.
+		// With SoftDelete the soft deleted documents are left out of filter, so they are never restored: a new document
+		// is inserted instead (the unique indexes must be partial on {deleted_at: {$type: "null"}} to allow it).
+		Upsert(ctx context.Context, filter interface{}, entity *T) error
+		// Delete soft deletes the document when SoftDelete is set, returning errorutils.ErrorNotFound when no live document has the id
+		Delete(ctx context.Context, id interface{}) error
+		// Purge deletes the document for good, soft deleted or not, returning errorutils.ErrorNotFound when no document has the id
+		Purge(ctx context.Context, id interface{}) error
+		// Aggregate runs pipeline followed by the keyword match, and pages its output with a $facet counting the total
+		Aggregate(ctx context.Context, pipeline mongo.Pipeline, params paramquery.BaseParamQuery) ([]T, int, error)
+	}
+
+	MongoCollectionImpl[T any] struct {
+		db     database.DBCollection
+		config MongoCollectionConfig
+	}
+
+	facetResult[T any] struct {
+		Data  []T `bson:"data"`
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+	}
+)
+
+func NewMongoCollection[T any](db database.DBCollection, config MongoCollectionConfig) MongoCollection[T] {
+	if config.Sort == nil {
+		config.Sort = bson.D{{Key: "_id", Value: 1}}
+	}
+
+	return &MongoCollectionImpl[T]{
+		db:     db,
+		config: config,
+	}
+}
+
+func (c *MongoCollectionImpl[T]) collection() *mongo.Collection {
+	return c.db.MongoDB.Collection(c.config.Collection)
+}
+
+func (c *MongoCollectionImpl[T]) FindByID(ctx context.Context, id interface{}) (T, error) {
+	var entity T
+
+	if err := c.collection().FindOne(ctx, c.match(ctx, bson.M{"_id": mongoID(id)}, paramquery.BaseParamQuery{})).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return entity, errorutils.ErrorNotFound
+		}
+		return entity, fmt.Errorf("error when finding %s %v, error: %w", c.config.Collection, id, err)
+	}
+
+	return entity, nil
+}
+
+func (c *MongoCollectionImpl[T]) Find(ctx context.Context, filter interface{}, params paramquery.BaseParamQuery) ([]T, int, error) {
+	filter = c.match(ctx, filter, params)
+
+	total, err := c.collection().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error when counting %s, error: %w", c.config.Collection, err)
+	}
+
+	opts := options.Find().SetSort(c.config.Sort)
+	if params.Limit > 0 {
+		opts.SetSkip(int64(params.Offset)).SetLimit(int64(params.Limit))
+	}
+
+	cursor, err := c.collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error when finding %s, error: %w", c.config.Collection, err)
+	}
+
+	data := make([]T, 0)
+	if err := cursor.All(ctx, &data); err != nil {
+		return nil, 0, fmt.Errorf("error when decoding %s, error: %w", c.config.Collection, err)
+	}
+
+	return data, int(total), nil
+}
+
+func (c *MongoCollectionImpl[T]) Insert(ctx context.Context, entity *T) (interface{}, error) {
+	if auditable, ok := any(entity).(model.Auditable); ok {
+		auditable.TouchCreated(ctx)
+	}
+	if versioned, ok := any(entity).(model.Versioned); ok {
+		versioned.SetVersion(1)
+	}
+
+	result, err := c.collection().InsertOne(ctx, entity)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errorutils.ErrorDuplicateData
+		}
+		return nil, fmt.Errorf("error when inserting %s, error: %w", c.config.Collection, err)
+	}
+
+	return result.InsertedID, nil
+}
+
+func (c *MongoCollectionImpl[T]) Update(ctx context.Context, id interface{}, update interface{}) error {
+	filter := c.match(ctx, c.byID(ctx, id), paramquery.BaseParamQuery{})
+	result, err := c.collection().UpdateOne(ctx, filter, c.touchUpdate(ctx, update))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorutils.ErrorDuplicateData
+		}
+		return fmt.Errorf("error when updating %s %v, error: %w", c.config.Collection, id, err)
+	}
+	if result.MatchedCount == 0 {
+		return c.notFoundOrConflict(ctx, id)
+	}
+
+	return nil
+}
+
+func (c *MongoCollectionImpl[T]) Upsert(ctx context.Context, filter interface{}, entity *T) error {
+	if auditable, ok := any(entity).(model.Auditable); ok {
+		auditable.TouchCreated(ctx)
+	}
+
+	update, err := c.upsertUpdate(entity)
+	if err != nil {
+		return err
+	}
+	filter = c.match(ctx, filter, paramquery.BaseParamQuery{})
+	if _, err := c.collection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorutils.ErrorDuplicateData
+		}
+		return fmt.Errorf("error when upserting %s, error: %w", c.config.Collection, err)
+	}
+
+	return nil
+}
+
+func (c *MongoCollectionImpl[T]) Delete(ctx context.Context, id interface{}) error {
+	if !c.config.SoftDelete {
+		return c.Purge(ctx, id)
+	}
+
+	filter := c.byID(ctx, id)
+	filter[FIELD_DELETED_AT] = nil
+	result, err := c.collection().UpdateOne(ctx, filter, c.touchUpdate(ctx, bson.M{"$set": bson.M{FIELD_DELETED_AT: time.Now()}}))
+	if err != nil {
+		return fmt.Errorf("error when deleting %s %v, error: %w", c.config.Collection, id, err)
+	}
+	if result.MatchedCount == 0 {
+		return c.notFoundOrConflict(ctx, id)
+	}
+
+	return nil
+}
+
+func (c *MongoCollectionImpl[T]) Purge(ctx context.Context, id interface{}) error {
+	result, err := c.collection().DeleteOne(ctx, c.byID(ctx, id))
+	if err != nil {
+		return fmt.Errorf("error when purging %s %v, error: %w", c.config.Collection, id, err)
+	}
+	if result.DeletedCount == 0 {
+		return c.notFoundOrConflict(ctx, id)
+	}
+
+	return nil
+}
+
+func (c *MongoCollectionImpl[T]) Aggregate(ctx context.Context, pipeline mongo.Pipeline, params paramquery.BaseParamQuery) ([]T, int, error) {
+	stages := make(mongo.Pipeline, 0, len(pipeline)+3)
+	// the soft deleted documents are left out before the pipeline reshapes them,
+	// $text must be in the first stage of a pipeline
+	keyword := c.keyword(params)
+	if c.config.TextSearch && keyword != nil {
+		stages = append(stages, bson.D{{Key: "$match", Value: c.match(ctx, nil, params)}})
+		stages = append(stages, pipeline...)
+	} else {
+		if live := c.live(ctx); live != nil {
+			stages = append(stages, bson.D{{Key: "$match", Value: live}})
+		}
+		stages = append(stages, pipeline...)
+		if keyword != nil {
+			stages = append(stages, bson.D{{Key: "$match", Value: keyword}})
+		}
+	}
+
+	// a $facet sub-pipeline can not be empty, so $skip is always there
+	page := bson.A{bson.D{{Key: "$skip", Value: params.Offset}}}
+	if params.Limit > 0 {
+		page = append(page, bson.D{{Key: "$limit", Value: params.Limit}})
+	}
+	stages = append(stages, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "data", Value: page},
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+	}}})
+
+	cursor, err := c.collection().Aggregate(ctx, stages)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error when aggregating %s, error: %w", c.config.Collection, err)
+	}
+
+	var results []facetResult[T]
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, fmt.Errorf("error when decoding %s, error: %w", c.config.Collection, err)
+	}
+
+	data, total := make([]T, 0), 0
+	if len(results) > 0 {
+		if results[0].Data != nil {
+			data = results[0].Data
+		}
+		if len(results[0].Total) > 0 {
+			total = results[0].Total[0].Count
+		}
+	}
+
+	return data, total, nil
+}
+
+// match adds the keyword and soft delete conditions to filter, matching every document when all are empty
+func (c *MongoCollectionImpl[T]) match(ctx context.Context, filter interface{}, params paramquery.BaseParamQuery) interface{} {
+	conditions := bson.A{}
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+	if keyword := c.keyword(params); keyword != nil {
+		conditions = append(conditions, keyword)
+	}
+	if live := c.live(ctx); live != nil {
+		conditions = append(conditions, live)
+	}
+
+	switch len(conditions) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return conditions[0]
+	default:
+		return bson.M{"$and": conditions}
+	}
+}
+
+// live leaves out the soft deleted documents, nil when SoftDelete is unset or ctx comes from WithDeleted
+func (c *MongoCollectionImpl[T]) live(ctx context.Context) bson.M {
+	if !c.config.SoftDelete || withDeleted(ctx) {
+		return nil
+	}
+	return bson.M{FIELD_DELETED_AT: nil}
+}
+
+// touchUpdate adds to update (bson.M or bson.D) the updated fields of model.MongoAudit when T is auditable
+// and the version increment when T is versioned, keeping the fields already set.
+// Other updates (e.g. pipelines) are left untouched.
+func (c *MongoCollectionImpl[T]) touchUpdate(ctx context.Context, update interface{}) interface{} {
+	if _, ok := any(new(T)).(model.Auditable); ok {
+		update = withOperatorFields(update, "$set", bson.D{{Key: FIELD_UPDATED_AT, Value: time.Now()}, {Key: FIELD_UPDATED_BY, Value: tools.Actor(ctx)}})
+	}
+	if c.versioned() {
+		update = withOperatorFields(update, "$inc", bson.D{{Key: FIELD_VERSION, Value: 1}})
+	}
+	return update
+}
+
+// upsertUpdate splits the fields of entity into the $set of every upsert and the $setOnInsert of the one inserting,
+// the version is incremented with $inc, which starts it at 1 on insert
+func (c *MongoCollectionImpl[T]) upsertUpdate(entity *T) (bson.D, error) {
+	raw, err := bson.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding %s, error: %w", c.config.Collection, err)
+	}
+	var document bson.D
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("error when encoding %s, error: %w", c.config.Collection, err)
+	}
+
+	_, auditable := any(entity).(model.Auditable)
+	versioned := c.versioned()
+	set, setOnInsert := bson.D{}, bson.D{}
+	for _, element := range document {
+		switch {
+		case element.Key == "_id":
+			if element.Value != nil && !reflect.ValueOf(element.Value).IsZero() {
+				setOnInsert = append(setOnInsert, element)
+			}
+		case auditable && (element.Key == FIELD_CREATED_AT || element.Key == FIELD_CREATED_BY):
+			setOnInsert = append(setOnInsert, element)
+		case versioned && element.Key == FIELD_VERSION:
+		default:
+			set = append(set, element)
+		}
+	}
+
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(setOnInsert) > 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: setOnInsert})
+	}
+	if versioned {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: FIELD_VERSION, Value: 1}}})
+	}
+	return update, nil
+}
+
+// withOperatorFields adds fields to the operator (e.g. $set) of update
+func withOperatorFields(update interface{}, operator string, fields bson.D) interface{} {
+	switch update := update.(type) {
+	case bson.M:
+		merged := make(bson.M, len(update)+1)
+		for key, value := range update {
+			merged[key] = value
+		}
+		merged[operator] = mergeFields(update[operator], fields)
+		return merged
+	case bson.D:
+		merged := make(bson.D, 0, len(update)+1)
+		found := false
+		for _, element := range update {
+			if element.Key == operator {
+				element.Value, found = mergeFields(element.Value, fields), true
+			}
+			merged = append(merged, element)
+		}
+		if !found {
+			merged = append(merged, bson.E{Key: operator, Value: fields})
+		}
+		return merged
+	}
+	return update
+}
+
+// mergeFields adds fields to a document, keeping the ones already there
+func mergeFields(document interface{}, fields bson.D) interface{} {
+	switch document := document.(type) {
+	case nil:
+		return fields
+	case bson.M:
+		merged := make(bson.M, len(document)+len(fields))
+		for key, value := range document {
+			merged[key] = value
+		}
+		for _, field := range fields {
+			if _, ok := merged[field.Key]; !ok {
+				merged[field.Key] = field.Value
+			}
+		}
+		return merged
+	case bson.D:
+		merged := append(bson.D{}, document...)
+		for _, field := range fields {
+			if !slices.ContainsFunc(document, func(element bson.E) bool { return element.Key == field.Key }) {
+				merged = append(merged, field)
+			}
+		}
+		return merged
+	}
+	return document
+}
+
+func (c *MongoCollectionImpl[T]) versioned() bool {
+	_, ok := any(new(T)).(model.Versioned)
+	return ok
+}
+
+// byID matches the document of id, at the expected version of ctx when T is versioned (see WithExpectedVersion)
+func (c *MongoCollectionImpl[T]) byID(ctx context.Context, id interface{}) bson.M {
+	filter := bson.M{"_id": mongoID(id)}
+	if version, ok := expectedVersion(ctx); ok && c.versioned() {
+		filter[FIELD_VERSION] = version
+	}
+	return filter
+}
+
+// notFoundOrConflict explains why a write matched no document: httputils.ErrorVersionConflict when an expected version
+// was given and the document of id is there (so at another version), errorutils.ErrorNotFound otherwise
+func (c *MongoCollectionImpl[T]) notFoundOrConflict(ctx context.Context, id interface{}) error {
+	if _, ok := expectedVersion(ctx); !ok || !c.versioned() {
+		return errorutils.ErrorNotFound
+	}
+
+	count, err := c.collection().CountDocuments(ctx, c.match(ctx, bson.M{"_id": mongoID(id)}, paramquery.BaseParamQuery{}))
+	if err != nil {
+		return fmt.Errorf("error when checking %s %v, error: %w", c.config.Collection, id, err)
+	}
+	if count > 0 {
+		return httputils.ErrorVersionConflict
+	}
+
+	return errorutils.ErrorNotFound
+}
+
+// keyword matches the keyword against the search fields or the text index, nil when there is nothing to match
+func (c *MongoCollectionImpl[T]) keyword(params paramquery.BaseParamQuery) bson.M {
+	if params.Keyword == nil || *params.Keyword == "" {
+		return nil
+	}
+	if c.config.TextSearch {
+		return bson.M{"$text": bson.M{"$search": *params.Keyword}}
+	}
+	if len(c.config.SearchFields) == 0 {
+		return nil
+	}
+
+	conditions := make(bson.A, len(c.config.SearchFields))
+	for i, field := range c.config.SearchFields {
+		conditions[i] = bson.M{field: primitive.Regex{Pattern: regexp.QuoteMeta(*params.Keyword), Options: "i"}}
+	}
+	return bson.M{"$or": conditions}
+}
+
+// mongoID converts an ObjectID hex string to an ObjectID, leaving any other id untouched
+func mongoID(id interface{}) interface{} {
+	if hex, ok := id.(string); ok {
+		if objectID, err := primitive.ObjectIDFromHex(hex); err == nil {
+			return objectID
+		}
+	}
+	return id
+}
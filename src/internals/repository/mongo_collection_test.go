@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/model"
+	"go-chi-boilerplate/utils/paramquery"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mongoTestDocument struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	Name             string             `bson:"name"`
+	model.MongoAudit `bson:",inline"`
+	model.Versioning `bson:",inline"`
+}
+
+func newMongoTestCollection(config MongoCollectionConfig) *MongoCollectionImpl[mongoTestDocument] {
+	return NewMongoCollection[mongoTestDocument](database.DBCollection{}, config).(*MongoCollectionImpl[mongoTestDocument])
+}
+
+func TestMongoCollectionUpsertUpdate(t *testing.T) {
+	id := primitive.NewObjectID()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		document mongoTestDocument
+		want     []string // the operators and their fields, in order
+	}{
+		{
+			name:     "new document",
+			document: mongoTestDocument{Name: "a", MongoAudit: model.MongoAudit{CreatedAt: created, UpdatedAt: created}},
+			want:     []string{"$set", "name", "updated_at", "updated_by", "deleted_at", "$setOnInsert", "created_at", "created_by", "$inc", "version"},
+		},
+		{
+			name:     "with an id",
+			document: mongoTestDocument{ID: id, Name: "a", Versioning: model.Versioning{Version: 4}},
+			want:     []string{"$set", "name", "updated_at", "updated_by", "deleted_at", "$setOnInsert", "_id", "created_at", "created_by", "$inc", "version"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := newMongoTestCollection(MongoCollectionConfig{Collection: "examples"}).upsertUpdate(&tt.document)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, operator := range update {
+				got = append(got, operator.Key)
+				for _, field := range operator.Value.(bson.D) {
+					got = append(got, field.Key)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("upsertUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithOperatorFields(t *testing.T) {
+	fields := bson.D{{Key: "updated_by", Value: "actor"}}
+
+	tests := []struct {
+		name   string
+		update interface{}
+		want   interface{}
+	}{
+		{
+			name:   "bson.M without the operator",
+			update: bson.M{"$unset": bson.M{"name": ""}},
+			want:   bson.M{"$unset": bson.M{"name": ""}, "$set": fields},
+		},
+		{
+			name:   "bson.M keeps the fields already set",
+			update: bson.M{"$set": bson.M{"name": "a", "updated_by": "other"}},
+			want:   bson.M{"$set": bson.M{"name": "a", "updated_by": "other"}},
+		},
+		{
+			name:   "bson.D with the operator",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}}}},
+			want:   bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}, {Key: "updated_by", Value: "actor"}}}},
+		},
+		{
+			name:   "bson.D without the operator",
+			update: bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}}},
+			want:   bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}}, {Key: "$set", Value: fields}},
+		},
+		{
+			name:   "pipeline left untouched",
+			update: []bson.D{{{Key: "$set", Value: bson.D{}}}},
+			want:   []bson.D{{{Key: "$set", Value: bson.D{}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withOperatorFields(tt.update, "$set", fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withOperatorFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoCollectionMatch(t *testing.T) {
+	keyword := "a.b"
+	regex := primitive.Regex{Pattern: `a\.b`, Options: "i"}
+
+	tests := []struct {
+		name   string
+		config MongoCollectionConfig
+		ctx    context.Context
+		filter interface{}
+		params paramquery.BaseParamQuery
+		want   interface{}
+	}{
+		{name: "everything", want: bson.M{}},
+		{name: "filter", filter: bson.M{"name": "a"}, want: bson.M{"name": "a"}},
+		{
+			name:   "keyword",
+			config: MongoCollectionConfig{SearchFields: []string{"name", "tag"}},
+			params: paramquery.BaseParamQuery{Keyword: &keyword},
+			want:   bson.M{"$or": bson.A{bson.M{"name": regex}, bson.M{"tag": regex}}},
+		},
+		{
+			name:   "text search",
+			config: MongoCollectionConfig{TextSearch: true},
+			params: paramquery.BaseParamQuery{Keyword: &keyword},
+			want:   bson.M{"$text": bson.M{"$search": keyword}},
+		},
+		{
+			name:   "soft delete",
+			config: MongoCollectionConfig{SoftDelete: true},
+			filter: bson.M{"name": "a"},
+			want:   bson.M{"$and": bson.A{bson.M{"name": "a"}, bson.M{FIELD_DELETED_AT: nil}}},
+		},
+		{
+			name:   "soft delete with deleted",
+			config: MongoCollectionConfig{SoftDelete: true},
+			ctx:    WithDeleted(context.Background()),
+			want:   bson.M{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if got := newMongoTestCollection(tt.config).match(ctx, tt.filter, tt.params); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoCollectionByID(t *testing.T) {
+	id := primitive.NewObjectID()
+	c := newMongoTestCollection(MongoCollectionConfig{})
+
+	if got, want := c.byID(context.Background(), id.Hex()), (bson.M{"_id": id}); !reflect.DeepEqual(got, want) {
+		t.Errorf("byID() = %v, want %v", got, want)
+	}
+	if got, want := c.byID(WithExpectedVersion(context.Background(), 3), "key"), (bson.M{"_id": "key", FIELD_VERSION: int64(3)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("byID() = %v, want %v", got, want)
+	}
+}
+
+// the soft deleted documents are never restored by Upsert, so they must not hold the unique values of the live ones
+func TestCollectionSpecsUniqueIndexesLive(t *testing.T) {
+	live := bson.M{FIELD_DELETED_AT: bson.M{"$type": "null"}}
+	for _, spec := range CollectionSpecs() {
+		for _, index := range spec.Indexes {
+			if index.Unique && !reflect.DeepEqual(index.PartialFilter, live) {
+				t.Errorf("unique index %v of %s has partial filter %v, want %v", index.Keys, spec.Name, index.PartialFilter, live)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import "context"
+
+type deletedContextKey struct{}
+
+// WithDeleted includes the soft deleted rows and documents in the reads and updates of ctx
+//
+//	Usage example:
+//		example, err := r.Get(repository.WithDeleted(ctx), id)
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedContextKey{}, true)
+}
+
+func withDeleted(ctx context.Context) bool {
+	deleted, _ := ctx.Value(deletedContextKey{}).(bool)
+	return deleted
+}
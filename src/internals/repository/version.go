@@ -0,0 +1,16 @@
+package repository
+
+import "context"
+
+type expectedVersionContextKey struct{}
+
+// WithExpectedVersion makes the updates and deletes of ctx fail with httputils.ErrorVersionConflict
+// unless the item is at version (e.g. the one sent with If-Match, see httputils.IfMatch)
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionContextKey{}, version)
+}
+
+func expectedVersion(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(expectedVersionContextKey{}).(int64)
+	return version, ok
+}
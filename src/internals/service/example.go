@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"go-chi-boilerplate/src/database"
 	"go-chi-boilerplate/src/internals/repository"
 	"go-chi-boilerplate/src/model"
 )
@@ -9,15 +10,23 @@ import (
 type (
 	ExampleService interface {
 		GetExample(ctx context.Context) model.ExampleResponse
+		CreateExample(ctx context.Context, req model.ExampleRequest) (model.Example, error)
+		GetExampleByID(ctx context.Context, id int64) (model.Example, error)
+		// UpdateExample fails with httputils.ErrorVersionConflict unless the example is at version (nil skips the check)
+		UpdateExample(ctx context.Context, id int64, version *int64, req model.ExampleRequest) (model.Example, error)
+		// DeleteExample fails with httputils.ErrorVersionConflict unless the example is at version (nil skips the check)
+		DeleteExample(ctx context.Context, id int64, version *int64) error
 	}
 
 	ExampleServiceImpl struct {
+		txManager   database.TxManager
 		exampleRepo repository.ExampleRepository
 	}
 )
 
-func NewExampleService(e repository.ExampleRepository) ExampleService {
+func NewExampleService(txManager database.TxManager, e repository.ExampleRepository) ExampleService {
 	return &ExampleServiceImpl{
+		txManager:   txManager,
 		exampleRepo: e,
 	}
 }
@@ -25,3 +34,43 @@ func NewExampleService(e repository.ExampleRepository) ExampleService {
 func (s *ExampleServiceImpl) GetExample(ctx context.Context) model.ExampleResponse {
 	return s.exampleRepo.GetExample(ctx)
 }
+
+func (s *ExampleServiceImpl) CreateExample(ctx context.Context, req model.ExampleRequest) (model.Example, error) {
+	example := model.Example{Name: req.Name}
+	if err := s.exampleRepo.Create(ctx, &example); err != nil {
+		return model.Example{}, err
+	}
+	return example, nil
+}
+
+func (s *ExampleServiceImpl) GetExampleByID(ctx context.Context, id int64) (model.Example, error) {
+	return s.exampleRepo.Get(ctx, id)
+}
+
+func (s *ExampleServiceImpl) UpdateExample(ctx context.Context, id int64, version *int64, req model.ExampleRequest) (model.Example, error) {
+	if version != nil {
+		ctx = repository.WithExpectedVersion(ctx, *version)
+	}
+
+	// the read and the write run in one transaction, so the read goes to the primary rather than a lagging replica
+	var example model.Example
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) (err error) {
+		example, err = s.exampleRepo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		example.Name = req.Name
+		return s.exampleRepo.Update(ctx, &example)
+	})
+	if err != nil {
+		return model.Example{}, err
+	}
+	return example, nil
+}
+
+func (s *ExampleServiceImpl) DeleteExample(ctx context.Context, id int64, version *int64) error {
+	if version != nil {
+		ctx = repository.WithExpectedVersion(ctx, *version)
+	}
+	return s.exampleRepo.Delete(ctx, id)
+}
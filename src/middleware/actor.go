@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"go-chi-boilerplate/src/tools"
+	"net/http"
+)
+
+const (
+	// HeaderActor carries the acting user, set by the gateway authenticating the caller
+	HeaderActor = "actor"
+	// ActorAnonymous is the actor of the requests without a trusted actor header
+	ActorAnonymous = "anonymous"
+)
+
+// Actor carries the acting user into the request context (see tools.Actor), recorded in the audit columns.
+// The actor header is only believed from the trusted proxies (config.Host.TrustedProxies), which authenticate
+// the caller; any other request is recorded as ActorAnonymous, so a client can't forge the audit trail.
+func (m *GoMiddlewareImpl) Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := ActorAnonymous
+		if header := r.Header.Get(HeaderActor); header != "" && m.fromTrustedProxy(r) {
+			actor = header
+		}
+
+		next.ServeHTTP(w, r.WithContext(tools.WithActor(r.Context(), actor)))
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"go-chi-boilerplate/src/tools"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestActor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		header         string
+		basicUser      string
+		want           string
+	}{
+		{name: "header from a trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", header: "alice", want: "alice"},
+		{name: "header from a trusted ipv4-mapped proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "[::ffff:10.1.2.3]:1234", header: "alice", want: "alice"},
+		{name: "header from another peer", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "192.0.2.1:1234", header: "alice", want: ActorAnonymous},
+		{name: "header without trusted proxy", remoteAddr: "10.1.2.3:1234", header: "alice", want: ActorAnonymous},
+		{name: "unverified basic auth", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", basicUser: "bob", want: ActorAnonymous},
+		{name: "no header from a trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", want: ActorAnonymous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &GoMiddlewareImpl{}
+			m.setTrustedProxies(tt.trustedProxies)
+
+			var got string
+			handler := m.Actor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = tools.Actor(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set(HeaderActor, tt.header)
+			}
+			if tt.basicUser != "" {
+				req.SetBasicAuth(tt.basicUser, "secret")
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("tools.Actor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
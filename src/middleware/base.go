@@ -5,37 +5,67 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/go-chi/cors"
 	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/tools"
 	"io"
 	"log"
 	"net/http"
+	"net/netip"
+	"reflect"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 )
 
 type (
 	GoMiddleware interface {
 		LogRequest(next http.Handler) http.Handler
+		RequestID(next http.Handler) http.Handler
+		Actor(next http.Handler) http.Handler
 		RecoverPanic(next http.Handler) http.Handler
 		BasicAuth(username, password string) func(http.Handler) http.Handler
+		SwaggerAuth(next http.Handler) http.Handler
+		Cors(next http.Handler) http.Handler
+		RateLimit(next http.Handler) http.Handler
 	}
 
 	GoMiddlewareImpl struct {
-		Config config.Config
+		ConfigHolder   *config.Holder
+		cors           atomic.Pointer[cors.Cors]
+		trustedProxies atomic.Pointer[[]netip.Prefix]
+		rateLimiter    *rateLimiter
 	}
 )
 
 const (
+	HeaderRequestID = "request-id"
+
 	ParamQueryPage    = "page"
 	ParamQueryLimit   = "limit"
 	ParamQueryOffset  = "offset"
 	ParamQueryKeyword = "keyword"
 )
 
-func InitMiddleware(cfg config.Config) GoMiddleware {
-	return &GoMiddlewareImpl{
-		Config: cfg,
+func InitMiddleware(cfgHolder *config.Holder) GoMiddleware {
+	m := &GoMiddlewareImpl{
+		ConfigHolder: cfgHolder,
+		rateLimiter:  newRateLimiter(),
 	}
+
+	// rebuild the cors handler and the trusted proxies whenever they are reloaded
+	m.setCors(cfgHolder.Get().Cors)
+	m.setTrustedProxies(cfgHolder.Get().Host.TrustedProxies)
+	cfgHolder.Subscribe(func(event config.ChangeEvent) {
+		if !reflect.DeepEqual(event.Old.Cors, event.New.Cors) {
+			m.setCors(event.New.Cors)
+		}
+		if !reflect.DeepEqual(event.Old.Host.TrustedProxies, event.New.Host.TrustedProxies) {
+			m.setTrustedProxies(event.New.Host.TrustedProxies)
+		}
+	})
+
+	return m
 }
 
 func (m *GoMiddlewareImpl) LogRequest(next http.Handler) http.Handler {
@@ -47,6 +77,21 @@ func (m *GoMiddlewareImpl) LogRequest(next http.Handler) http.Handler {
 	})
 }
 
+// RequestID carries the request-id header into the request context (see tools.RequestID), generating one when missing.
+// The id is echoed in the response headers.
+func (m *GoMiddlewareImpl) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderRequestID)
+		if requestID == "" {
+			requestID = tools.NewRequestID()
+			r.Header.Set(HeaderRequestID, requestID)
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+
+		next.ServeHTTP(w, r.WithContext(tools.WithRequestID(r.Context(), requestID)))
+	})
+}
+
 // MapLogRequest for map log request
 func MapLogRequest(r *http.Request) string {
 	rHeader := r.Header
@@ -67,7 +112,7 @@ func MapLogRequest(r *http.Request) string {
 		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
 	reqId := "-"
-	if requestId := rHeader.Get("request-id"); requestId != "" {
+	if requestId := rHeader.Get(HeaderRequestID); requestId != "" {
 		reqId = requestId
 	}
 
@@ -95,6 +140,14 @@ func (m *GoMiddlewareImpl) RecoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// SwaggerAuth protects the swagger docs with the swagger credentials currently in effect
+func (m *GoMiddlewareImpl) SwaggerAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := m.ConfigHolder.Get()
+		m.BasicAuth(cfg.SwaggerUsername, cfg.SwaggerPassword)(next).ServeHTTP(w, r)
+	})
+}
+
 func (m *GoMiddlewareImpl) BasicAuth(username, password string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
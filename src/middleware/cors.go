@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/go-chi/cors"
+	"go-chi-boilerplate/src/config"
+	"net/http"
+)
+
+var defaultAllowedOrigins = []string{"https://*", "http://*"}
+
+func (m *GoMiddlewareImpl) setCors(cfg config.Cors) {
+	allowedOrigins := cfg.AllowedOrigins
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = defaultAllowedOrigins
+	}
+
+	m.cors.Store(cors.New(cors.Options{
+		AllowedOrigins:   allowedOrigins,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300, // Maximum value not ignored by any of major browsers
+	}))
+}
+
+// Cors applies the CORS policy currently in effect
+func (m *GoMiddlewareImpl) Cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.cors.Load().Handler(next).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+)
+
+// setTrustedProxies parses the CIDRs of the trusted proxies, skipping the invalid ones (rejected by the config validation)
+func (m *GoMiddlewareImpl) setTrustedProxies(cidrs []string) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			logrus.Errorf("invalid trusted proxy %q, error: %v", cidr, err)
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	m.trustedProxies.Store(&prefixes)
+}
+
+// fromTrustedProxy reports whether the peer of r is one of the trusted proxies
+func (m *GoMiddlewareImpl) fromTrustedProxy(r *http.Request) bool {
+	return m.trustedProxy(remoteHost(r))
+}
+
+// trustedProxy reports whether the IP host is in one of the trusted proxies CIDRs
+func (m *GoMiddlewareImpl) trustedProxy(host string) bool {
+	prefixes := m.trustedProxies.Load()
+	if prefixes == nil || len(*prefixes) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client of r: the peer, or when the peer is a trusted proxy the right-most
+// X-Forwarded-For entry that is not a trusted proxy (the entries on its left are set by the client, so never believed)
+func (m *GoMiddlewareImpl) clientIP(r *http.Request) string {
+	client := remoteHost(r)
+	if !m.trustedProxy(client) {
+		return client
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values(HeaderForwardedFor), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		client = hop
+		if !m.trustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// remoteHost returns the IP of the peer of r, without its port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		want           string
+	}{
+		{name: "peer", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "forwarded for without trusted proxy", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"192.0.2.1"}, want: "10.1.2.3"},
+		{name: "forwarded for from another peer", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "192.0.2.9:1234", forwardedFor: []string{"192.0.2.1"}, want: "192.0.2.9"},
+		{name: "forwarded for from a trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"192.0.2.1"}, want: "192.0.2.1"},
+		{name: "spoofed entries left of the client", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.7, 192.0.2.1"}, want: "192.0.2.1"},
+		{name: "chain of trusted proxies", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.7, 192.0.2.1", "10.4.5.6"}, want: "192.0.2.1"},
+		{name: "only trusted proxies", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.7.8.9, 10.4.5.6"}, want: "10.7.8.9"},
+		{name: "no forwarded for from a trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &GoMiddlewareImpl{}
+			m.setTrustedProxies(tt.trustedProxies)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add(HeaderForwardedFor, value)
+			}
+
+			if got := m.clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitWindow = time.Minute
+)
+
+type (
+	// rateLimiter holds a token bucket per client IP: a bucket holds up to limit tokens, refilled at limit per window,
+	// and a request takes one. Full buckets are evicted, so only the clients seen within a window are kept.
+	rateLimiter struct {
+		mu        sync.Mutex
+		buckets   map[string]*tokenBucket
+		lastSweep time.Time
+	}
+
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether the client has a token left at now, and otherwise how long until the next one
+func (l *rateLimiter) allow(client string, limit int, window time.Duration, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a bucket idle for a window is full again, as good as a new one
+	if now.Sub(l.lastSweep) >= window {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.last) >= window {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	rate := float64(limit) / window.Seconds()
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit), last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// RateLimit rejects the requests above the limit currently in effect with 429 Too Many Requests,
+// counting them per client IP (see clientIP)
+func (m *GoMiddlewareImpl) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := m.ConfigHolder.Get().RateLimit
+		if cfg.Requests <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		window := time.Second * time.Duration(cfg.Window)
+		if window <= 0 {
+			window = defaultRateLimitWindow
+		}
+
+		allowed, retryAfter := m.rateLimiter.allow(m.clientIP(r), cfg.Requests, window, time.Now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
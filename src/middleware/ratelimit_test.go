@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type request struct {
+		client         string
+		at             time.Duration // since start
+		want           bool
+		wantRetryAfter time.Duration
+	}
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "burst up to the limit",
+			requests: []request{
+				{client: "a", want: true},
+				{client: "a", want: true},
+				{client: "a", want: false, wantRetryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "clients counted apart",
+			requests: []request{
+				{client: "a", want: true},
+				{client: "a", want: true},
+				{client: "b", want: true},
+				{client: "a", want: false, wantRetryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "refilled over the window",
+			requests: []request{
+				{client: "a", want: true},
+				{client: "a", want: true},
+				{client: "a", at: 20 * time.Second, want: false, wantRetryAfter: 10 * time.Second},
+				{client: "a", at: 30 * time.Second, want: true},
+				{client: "a", at: 30 * time.Second, want: false, wantRetryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "no window edge burst",
+			requests: []request{
+				{client: "a", at: 59 * time.Second, want: true},
+				{client: "a", at: 59 * time.Second, want: true},
+				{client: "a", at: 61 * time.Second, want: false, wantRetryAfter: 28 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter()
+			for i, req := range tt.requests {
+				got, retryAfter := l.allow(req.client, 2, time.Minute, start.Add(req.at))
+				if got != req.want || retryAfter.Round(time.Millisecond) != req.wantRetryAfter {
+					t.Fatalf("request %d allow() = %v, %v, want %v, %v", i, got, retryAfter, req.want, req.wantRetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterEvictsIdleClients(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := newRateLimiter()
+
+	for _, client := range []string{"a", "b", "c"} {
+		l.allow(client, 2, time.Minute, start)
+	}
+	l.allow("b", 2, time.Minute, start.Add(30*time.Second))
+	l.allow("d", 2, time.Minute, start.Add(70*time.Second))
+
+	if len(l.buckets) != 2 || l.buckets["b"] == nil || l.buckets["d"] == nil {
+		t.Errorf("buckets = %v, want the ones of b and d", l.buckets)
+	}
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"context"
+	"go-chi-boilerplate/src/tools"
+	"gorm.io/gorm"
+	"time"
+)
+
+type (
+	// Auditable is implemented by the models embedding Audit or MongoAudit, the repositories call it before writing them
+	Auditable interface {
+		// TouchCreated sets the created and updated fields to now and the actor of ctx
+		TouchCreated(ctx context.Context)
+		// TouchUpdated sets the updated fields to now and the actor of ctx, and the created ones when still unset
+		TouchUpdated(ctx context.Context)
+	}
+
+	// Audit holds the audit columns of the SQL models, filled by the gorm hooks and the CRUD repositories.
+	// Setting deleted_at soft deletes the row, gorm and CRUD exclude it from the queries.
+	//
+	//	Usage example:
+	//		Example struct {
+	//			ID   int64  `json:"id" db:"id" crud:"pk"`
+	//			Name string `json:"name" db:"name"`
+	//			model.Audit
+	//		}
+	Audit struct {
+		CreatedAt time.Time      `json:"created_at" db:"created_at" crud:"createonly"`
+		UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+		CreatedBy string         `json:"created_by" db:"created_by" crud:"createonly"`
+		UpdatedBy string         `json:"updated_by" db:"updated_by"`
+		DeletedAt gorm.DeletedAt `json:"deleted_at" db:"deleted_at" crud:"softdelete" gorm:"index" swaggertype:"string" format:"date-time"`
+	}
+
+	// MongoAudit holds the audit fields of the mongodb documents, filled by the MongoCollection repositories,
+	// embedded with the bson:",inline" tag. deleted_at is stored as null until the document is soft deleted,
+	// so {deleted_at: null} matches the live ones.
+	MongoAudit struct {
+		CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at" bson:"updated_at"`
+		CreatedBy string     `json:"created_by" bson:"created_by"`
+		UpdatedBy string     `json:"updated_by" bson:"updated_by"`
+		DeletedAt *time.Time `json:"deleted_at" bson:"deleted_at"`
+	}
+)
+
+func (a *Audit) TouchCreated(ctx context.Context) {
+	a.CreatedAt, a.CreatedBy = time.Now(), tools.Actor(ctx)
+	a.UpdatedAt, a.UpdatedBy = a.CreatedAt, a.CreatedBy
+}
+
+func (a *Audit) TouchUpdated(ctx context.Context) {
+	a.UpdatedAt, a.UpdatedBy = time.Now(), tools.Actor(ctx)
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt, a.CreatedBy = a.UpdatedAt, a.UpdatedBy
+	}
+}
+
+// BeforeCreate is the gorm hook filling the audit columns of a created row
+func (a *Audit) BeforeCreate(tx *gorm.DB) error {
+	a.TouchCreated(tx.Statement.Context)
+	return nil
+}
+
+// BeforeUpdate is the gorm hook filling the updated_by column, also when updating with Update or Updates
+// (gorm fills updated_at itself)
+func (a *Audit) BeforeUpdate(tx *gorm.DB) error {
+	tx.Statement.SetColumn("updated_by", tools.Actor(tx.Statement.Context))
+	return nil
+}
+
+func (a *MongoAudit) TouchCreated(ctx context.Context) {
+	a.CreatedAt, a.CreatedBy = time.Now(), tools.Actor(ctx)
+	a.UpdatedAt, a.UpdatedBy = a.CreatedAt, a.CreatedBy
+}
+
+func (a *MongoAudit) TouchUpdated(ctx context.Context) {
+	a.UpdatedAt, a.UpdatedBy = time.Now(), tools.Actor(ctx)
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt, a.CreatedBy = a.UpdatedAt, a.UpdatedBy
+	}
+}
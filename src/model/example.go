@@ -1,6 +1,19 @@
 package model
 
 type (
+	// Example is a row of the examples table
+	Example struct {
+		ID   int64  `json:"id" db:"id" crud:"pk"`
+		Name string `json:"name" db:"name"`
+		Audit
+		Versioning
+	}
+
+	// ExampleRequest is the payload creating or updating an example
+	ExampleRequest struct {
+		Name string `json:"name" validate:"required"`
+	}
+
 	ExampleResponse struct {
 		AppName string `json:"app_name"`
 		Env     string `json:"env"`
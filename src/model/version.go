@@ -0,0 +1,32 @@
+package model
+
+type (
+	// Versioned is implemented by the models embedding Versioning, the MongoCollection repositories check and increment it
+	Versioned interface {
+		GetVersion() int64
+		SetVersion(version int64)
+	}
+
+	// Versioning is the optimistic lock of a model: the CRUD and MongoCollection updates check the version it was read at
+	// and increment it, failing with httputils.ErrorVersionConflict when another update came first.
+	// Clients get it as ETag and send it back with If-Match.
+	// The gorm updates do not check nor increment it, update the versioned models through CRUD or MongoCollection.
+	//
+	//	Usage example:
+	//		Example struct {
+	//			ID   int64  `json:"id" db:"id" crud:"pk"`
+	//			Name string `json:"name" db:"name"`
+	//			model.Versioning `bson:",inline"`
+	//		}
+	Versioning struct {
+		Version int64 `json:"version" db:"version" bson:"version" crud:"version"`
+	}
+)
+
+func (v *Versioning) GetVersion() int64 {
+	return v.Version
+}
+
+func (v *Versioning) SetVersion(version int64) {
+	v.Version = version
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables used to hand the listening sockets over to the re-executed process
+const (
+	ENV_INHERITED_LISTENERS = "GRACEFUL_INHERITED_LISTENERS"
+	ENV_READY_FD            = "GRACEFUL_READY_FD"
+
+	// file descriptors passed through exec.Cmd.ExtraFiles start right after stdin, stdout and stderr
+	inheritedFdStart = 3
+
+	defaultRestartTimeout = time.Minute
+)
+
+// inheritedListeners returns the listeners passed by the parent process, keyed by endpoint name
+func inheritedListeners() (map[string]net.Listener, error) {
+	names := os.Getenv(ENV_INHERITED_LISTENERS)
+	os.Unsetenv(ENV_INHERITED_LISTENERS)
+	if names == "" {
+		return nil, nil
+	}
+
+	listeners := make(map[string]net.Listener)
+	for i, name := range strings.Split(names, ",") {
+		file := os.NewFile(uintptr(inheritedFdStart+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error when inheriting %s listener: %w", name, err)
+		}
+		listeners[name] = listener
+	}
+
+	return listeners, nil
+}
+
+// notifyReady tells the parent process (if any) that this process is serving, so the parent can start draining
+func notifyReady() error {
+	fdStr := os.Getenv(ENV_READY_FD)
+	os.Unsetenv(ENV_READY_FD)
+	if fdStr == "" {
+		return nil
+	}
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", ENV_READY_FD, err)
+	}
+
+	readyPipe := os.NewFile(uintptr(fd), "ready")
+	defer readyPipe.Close()
+
+	_, err = readyPipe.Write([]byte{1})
+	return err
+}
+
+// restart re-executes the current binary, passing it the listening sockets,
+// and waits until the new process reports it is serving.
+//
+//	When the new process fails to become ready within the restart timeout, or ctx is done first, it is killed,
+//	and the current process keeps serving (or shuts down when ctx is done).
+func (s *Server) restart(ctx context.Context, listeners []net.Listener) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error when resolving executable: %w", err)
+	}
+
+	var (
+		files = make([]*os.File, 0, len(listeners)+1)
+		names = make([]string, 0, len(listeners))
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for i, listener := range listeners {
+		tcpListener, ok := listener.(*net.TCPListener)
+		if !ok {
+			return fmt.Errorf("%s listener does not support handoff", s.endpoints[i].name)
+		}
+		file, err := tcpListener.File()
+		if err != nil {
+			return fmt.Errorf("error when duplicating %s listener: %w", s.endpoints[i].name, err)
+		}
+		files = append(files, file)
+		names = append(names, s.endpoints[i].name)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error when creating ready pipe: %w", err)
+	}
+	defer readyReader.Close()
+	files = append(files, readyWriter)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = s.handoffEnv(os.Environ(), names)
+
+	logrus.Printf("[INFO] re-executing %s to hand over %s listener(s)", executable, strings.Join(names, ", "))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error when starting new process: %w", err)
+	}
+
+	// only the child must hold the write end, so a crashing child closes the pipe
+	readyWriter.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyReader.Read(buf)
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err == nil {
+			logrus.Printf("[INFO] new process (pid %d) is ready", cmd.Process.Pid)
+			go cmd.Wait()
+			return nil
+		}
+		err = fmt.Errorf("new process exited before becoming ready: %w", err)
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	case <-time.After(s.restartTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.New("timeout when waiting for the new process to become ready")
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("shutdown when waiting for the new process to become ready: %w", ctx.Err())
+	}
+}
+
+// handoffEnv returns the environment of the re-executed process: env without the variables set by UnsetOnHandoff,
+// naming the inherited listeners and the descriptor of the ready pipe
+func (s *Server) handoffEnv(env []string, names []string) []string {
+	keys := append([]string{ENV_INHERITED_LISTENERS, ENV_READY_FD}, s.handoffUnsetEnv...)
+	return append(
+		filterEnv(env, keys...),
+		fmt.Sprintf("%s=%s", ENV_INHERITED_LISTENERS, strings.Join(names, ",")),
+		fmt.Sprintf("%s=%d", ENV_READY_FD, inheritedFdStart+len(names)),
+	)
+}
+
+func filterEnv(env []string, keys ...string) []string {
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		keep := true
+		for _, key := range keys {
+			if strings.HasPrefix(kv, key+"=") {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"go-chi-boilerplate/src/config"
+	"reflect"
+	"testing"
+)
+
+func TestServerHandoffEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		config.ENV_GOOGLE_APPLICATION_CREDENTIALS + "=/tmp/google-application-credentials-1/credentials.json",
+		ENV_INHERITED_LISTENERS + "=public",
+		ENV_READY_FD + "=4",
+	}
+
+	tests := []struct {
+		name  string
+		unset []string
+		names []string
+		want  []string
+	}{
+		{
+			name:  "credentials of the environment inherited",
+			names: []string{"public"},
+			want: []string{
+				"PATH=/usr/bin",
+				config.ENV_GOOGLE_APPLICATION_CREDENTIALS + "=/tmp/google-application-credentials-1/credentials.json",
+				ENV_INHERITED_LISTENERS + "=public",
+				ENV_READY_FD + "=4",
+			},
+		},
+		{
+			name:  "materialised credentials left out",
+			unset: []string{config.ENV_GOOGLE_APPLICATION_CREDENTIALS},
+			names: []string{"public", "admin"},
+			want: []string{
+				"PATH=/usr/bin",
+				ENV_INHERITED_LISTENERS + "=public,admin",
+				ENV_READY_FD + "=5",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			s.UnsetOnHandoff(tt.unset...)
+			if got := s.handoffEnv(env, tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("handoffEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package http
 import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go-chi-boilerplate/src/config"
 	"go-chi-boilerplate/src/internals/controller"
@@ -12,51 +11,102 @@ import (
 )
 
 func RegisterRouter(
-	cfg config.Config,
+	cfgHolder *config.Holder,
+	healthController controller.HealthController,
 	exampleController controller.ExampleController,
 	// register new controllers here
 ) chi.Router {
 	r := chi.NewRouter()
+	cfg := cfgHolder.Get()
 
-	mid := middleware.InitMiddleware(cfg)
+	mid := middleware.InitMiddleware(cfgHolder)
 
 	setMiddlewareGlobal(mid, r)
 
-	// Swagger
+	// Operational endpoints live on the admin listener when it is enabled,
+	// the probes are kept out of CORS and rate limiting so a busy service is not restarted by its kubelet
+	if !cfg.Host.AdminEnabled() {
+		setProbeRoutes(r, healthController)
+	}
+
+	r.Group(func(r chi.Router) {
+		// Cors
+		r.Use(mid.Cors)
+
+		// Rate Limit
+		r.Use(mid.RateLimit)
+
+		if !cfg.Host.AdminEnabled() {
+			setOperationalRoutes(cfg, mid, r, healthController)
+		}
+
+		r.Get("/example", exampleController.GetExample)
+
+		// the routes backed by the SQL datasource, left out when it is disabled rather than failing on its nil handles
+		if cfg.DataSource.SqlDBConfig.Enabled {
+			r.Post("/example", exampleController.CreateExample)
+			r.Get("/example/{id}", exampleController.GetExampleByID)
+			r.Put("/example/{id}", exampleController.UpdateExample)
+			r.Delete("/example/{id}", exampleController.DeleteExample)
+		}
+	})
+
+	return r
+}
+
+// RegisterAdminRouter registers the router served by the admin listener (swagger, probes, metrics, debug, etc.)
+func RegisterAdminRouter(
+	cfgHolder *config.Holder,
+	healthController controller.HealthController,
+) chi.Router {
+	r := chi.NewRouter()
+	cfg := cfgHolder.Get()
+
+	mid := middleware.InitMiddleware(cfgHolder)
+
+	// Recovery
+	r.Use(mid.RecoverPanic)
+
+	setProbeRoutes(r, healthController)
+	setOperationalRoutes(cfg, mid, r, healthController)
+
+	return r
+}
+
+func setOperationalRoutes(cfg config.Config, mid middleware.GoMiddleware, r chi.Router, healthController controller.HealthController) {
+	// Swagger and Debug, behind the swagger credentials as they may end up on the public listener
 	r.Group(func(r chi.Router) {
-		r.Use(mid.BasicAuth(cfg.SwaggerUsername, cfg.SwaggerPassword))
+		r.Use(mid.SwaggerAuth)
 		r.Route("/swagger", func(r chi.Router) {
 			r.Get("/*", httpSwagger.WrapHandler)
 			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 				http.Redirect(w, r, "/swagger/index.html", http.StatusMovedPermanently)
 			})
 		})
+		r.Get("/debug/db-pools", healthController.DBPools)
 	})
 
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		staticText := fmt.Sprintf("hello world: %s", cfg.Env)
 		w.Write([]byte(staticText))
 	})
+}
 
-	r.Get("/example", exampleController.GetExample)
-
-	return r
+func setProbeRoutes(r chi.Router, healthController controller.HealthController) {
+	r.Get("/healthz", healthController.Liveness)
+	r.Get("/readyz", healthController.Readiness)
 }
 
 func setMiddlewareGlobal(mid middleware.GoMiddleware, r *chi.Mux) {
+	// Request ID, first so the request log and the query logs share it
+	r.Use(mid.RequestID)
+
+	// Actor, recorded in the audit columns
+	r.Use(mid.Actor)
+
 	// Logger
 	r.Use(mid.LogRequest)
 
-	// Cors
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300, // Maximum value not ignored by any of major browsers
-	}))
-
 	// Recovery
 	r.Use(mid.RecoverPanic)
 }
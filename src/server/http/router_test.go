@@ -0,0 +1,114 @@
+package http
+
+import (
+	"go-chi-boilerplate/src/config"
+	"go-chi-boilerplate/src/database"
+	"go-chi-boilerplate/src/health"
+	"go-chi-boilerplate/src/internals/controller"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterRouterProbesSkipRateLimit(t *testing.T) {
+	cfg := config.Config{RateLimit: config.RateLimit{Requests: 1, Window: 60}}
+	router := RegisterRouter(
+		config.NewHolder(cfg),
+		controller.NewHealthController(health.NewRegistry(), nil),
+		controller.NewExampleController(nil),
+	)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/ping", want: http.StatusOK},
+		{path: "/ping", want: http.StatusTooManyRequests},
+		{path: "/healthz", want: http.StatusOK},
+		{path: "/readyz", want: http.StatusOK},
+		{path: "/healthz", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestRegisterRouterSqlRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		sqlEnabled bool
+		method     string
+		path       string
+		want       int
+	}{
+		{name: "static route without sql", method: http.MethodGet, path: "/example", want: http.StatusOK},
+		{name: "sql route without sql", method: http.MethodGet, path: "/example/1", want: http.StatusNotFound},
+		{name: "sql write without sql", method: http.MethodPost, path: "/example", want: http.StatusMethodNotAllowed},
+		{name: "sql route with sql", sqlEnabled: true, method: http.MethodGet, path: "/example/1", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{}
+			cfg.DataSource.SqlDBConfig.Enabled = tt.sqlEnabled
+			router := RegisterRouter(
+				config.NewHolder(cfg),
+				controller.NewHealthController(health.NewRegistry(), nil),
+				stubExampleController{},
+			)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// stubExampleController answers 200 to every route, standing in for the datasources
+type stubExampleController struct{}
+
+func (stubExampleController) GetExample(w http.ResponseWriter, r *http.Request)     {}
+func (stubExampleController) CreateExample(w http.ResponseWriter, r *http.Request)  {}
+func (stubExampleController) GetExampleByID(w http.ResponseWriter, r *http.Request) {}
+func (stubExampleController) UpdateExample(w http.ResponseWriter, r *http.Request)  {}
+func (stubExampleController) DeleteExample(w http.ResponseWriter, r *http.Request)  {}
+
+func TestRegisterRouterDebugBehindSwaggerAuth(t *testing.T) {
+	cfg := config.Config{SwaggerUsername: "admin", SwaggerPassword: "secret"}
+	router := RegisterRouter(
+		config.NewHolder(cfg),
+		controller.NewHealthController(health.NewRegistry(), database.NewPoolStatsCollector(database.DBCollection{}, 0)),
+		stubExampleController{},
+	)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{name: "no credentials", want: http.StatusUnauthorized},
+		{name: "wrong credentials", username: "admin", password: "wrong", want: http.StatusUnauthorized},
+		{name: "swagger credentials", username: "admin", password: "secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/db-pools", nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("GET /debug/db-pools = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/config"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+type (
+	// ShutdownHook is executed once the HTTP server has drained.
+	// Hooks run sequentially in the order they were registered.
+	ShutdownHook struct {
+		Name string
+		Fn   func(ctx context.Context) error
+	}
+
+	// endpoint is a single listener served by the Server (public or admin)
+	endpoint struct {
+		name       string
+		httpServer *http.Server
+	}
+
+	Server struct {
+		endpoints       []endpoint
+		shutdownTimeout time.Duration
+		gracefulRestart bool
+		restartTimeout  time.Duration
+		hooks           []ShutdownHook
+		// handoffUnsetEnv are the environment variables the re-executed process must not inherit
+		handoffUnsetEnv []string
+	}
+)
+
+// NewServer builds the public server, and the admin server when adminHandler is not nil.
+//
+//	The admin server never terminates TLS, it is meant to be reachable only from inside the cluster/VPC.
+func NewServer(cfg config.Config, handler http.Handler, adminHandler http.Handler) (*Server, error) {
+	shutdownTimeout := time.Second * time.Duration(cfg.Host.ShutdownTimeout)
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
+	publicServer := newHttpServer(cfg.Host, fmt.Sprintf("%s:%s", cfg.Host.Address, cfg.Host.Port), handler)
+	if cfg.Host.TLS.Enabled() {
+		tlsConfig, err := newTLSConfig(cfg.Host.TLS)
+		if err != nil {
+			return nil, err
+		}
+		publicServer.TLSConfig = tlsConfig
+	}
+
+	restartTimeout := time.Second * time.Duration(cfg.Host.RestartTimeout)
+	if restartTimeout <= 0 {
+		restartTimeout = defaultRestartTimeout
+	}
+
+	server := &Server{
+		endpoints:       []endpoint{{name: "public", httpServer: publicServer}},
+		shutdownTimeout: shutdownTimeout,
+		gracefulRestart: cfg.Host.GracefulRestart,
+		restartTimeout:  restartTimeout,
+	}
+
+	if adminHandler != nil {
+		adminServer := newHttpServer(cfg.Host, fmt.Sprintf("%s:%s", cfg.Host.AdminAddress, cfg.Host.AdminPort), adminHandler)
+		server.endpoints = append(server.endpoints, endpoint{name: "admin", httpServer: adminServer})
+	}
+
+	return server, nil
+}
+
+func newHttpServer(host config.Host, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		WriteTimeout: time.Second * time.Duration(host.WriteTimeout),
+		ReadTimeout:  time.Second * time.Duration(host.ReadTimeout),
+		IdleTimeout:  time.Second * time.Duration(host.IdleTimeout),
+		Handler:      handler,
+	}
+}
+
+// OnShutdown registers a hook (closing datasources, stopping background workers, etc.)
+// that is executed after the HTTP server has stopped accepting and finished serving requests.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, ShutdownHook{Name: name, Fn: fn})
+}
+
+// UnsetOnHandoff leaves the environment variables keys out of the environment of the process re-executed on SIGHUP,
+// for the values pointing to resources of this process (e.g. a temp file removed by a shutdown hook)
+func (s *Server) UnsetOnHandoff(keys ...string) {
+	s.handoffUnsetEnv = append(s.handoffUnsetEnv, keys...)
+}
+
+// Run starts every HTTP server and blocks until ctx is cancelled or one of the servers fails.
+//
+//	A failure to bind any listener (e.g. port already in use) is returned once the shutdown hooks have run,
+//	so the caller can exit with a non-zero status without leaking what the hooks release.
+//
+//	When graceful restart is enabled, SIGHUP re-executes the binary and hands the listeners over to it.
+//	This process only starts draining once the new one reports it is serving.
+func (s *Server) Run(ctx context.Context) error {
+	listeners, err := s.listen()
+	if err != nil {
+		return errors.Join(err, s.shutdown())
+	}
+
+	serveErr := make(chan error, len(s.endpoints))
+	for i, e := range s.endpoints {
+		go func(e endpoint, listener net.Listener) {
+			if err := serve(e, listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s http server stopped unexpectedly: %w", e.name, err)
+			}
+		}(e, listeners[i])
+	}
+
+	if err := notifyReady(); err != nil {
+		logrus.Errorf("error when notifying parent process, error: %v", err)
+	}
+
+	restart := make(chan os.Signal, 1)
+	if s.gracefulRestart {
+		signal.Notify(restart, syscall.SIGHUP)
+		defer signal.Stop(restart)
+	}
+
+	var runErr error
+wait:
+	for {
+		select {
+		case runErr = <-serveErr:
+			break wait
+		case <-ctx.Done():
+			logrus.Println("received shutdown signal. Trying to shutdown gracefully")
+			break wait
+		case <-restart:
+			logrus.Println("received restart signal. Handing listeners over to a new process")
+			if err := s.restart(ctx, listeners); err != nil {
+				logrus.Errorf("error when restarting, keep serving, error: %v", err)
+				continue
+			}
+			break wait
+		}
+	}
+
+	if err := s.shutdown(); err != nil {
+		return errors.Join(runErr, err)
+	}
+
+	return runErr
+}
+
+// listen binds a listener for every endpoint, reusing the ones inherited from a parent process
+func (s *Server) listen() ([]net.Listener, error) {
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		if listener, ok := inherited[e.name]; ok {
+			logrus.Printf("[INFO] using %s listener inherited from parent process", e.name)
+			delete(inherited, e.name)
+			listeners = append(listeners, listener)
+			continue
+		}
+
+		listener, err := net.Listen("tcp", e.httpServer.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("error when listening %s server on %s: %w", e.name, e.httpServer.Addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	// listeners no longer served by this configuration
+	for _, l := range inherited {
+		l.Close()
+	}
+
+	return listeners, nil
+}
+
+func serve(e endpoint, listener net.Listener) error {
+	if e.httpServer.TLSConfig != nil {
+		logrus.Printf("⇨ %s https server started on %s\n", e.name, listener.Addr())
+		// certificates are served by TLSConfig.GetCertificate
+		return e.httpServer.ServeTLS(listener, "", "")
+	}
+
+	logrus.Printf("⇨ %s http server started on %s\n", e.name, listener.Addr())
+	return e.httpServer.Serve(listener)
+}
+
+func (s *Server) shutdown() error {
+	var errs []error
+
+	// Stop Servers, the public one first so the admin endpoints stay reachable while it drains
+	logrus.Printf("Stopping HTTP Server (drain period %s)", s.shutdownTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancelDrain()
+
+	for _, e := range s.endpoints {
+		e.httpServer.SetKeepAlivesEnabled(false)
+		if err := e.httpServer.Shutdown(drainCtx); err != nil {
+			errs = append(errs, fmt.Errorf("error when shutting down %s http server: %w", e.name, err))
+		}
+	}
+
+	// Run hooks
+	hookCtx, cancelHook := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancelHook()
+
+	for _, hook := range s.hooks {
+		logrus.Printf("Running shutdown hook: %s", hook.Name)
+		if err := hook.Fn(hookCtx); err != nil {
+			errs = append(errs, fmt.Errorf("error when running shutdown hook %s: %w", hook.Name, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	logrus.Println("Shutdown gracefully completed")
+	return nil
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+	"go-chi-boilerplate/src/config"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServerRunBindFailureRunsHooks(t *testing.T) {
+	// the port the server fails to bind
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	host, port, err := net.SplitHostPort(busy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{}
+	cfg.Host.Address = host
+	cfg.Host.Port = port
+	server, err := NewServer(cfg, http.NotFoundHandler(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	for _, name := range []string{"first", "second"} {
+		server.OnShutdown(name, func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+
+	err = server.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "error when listening public server") {
+		t.Fatalf("Run() error = %v, want the bind failure", err)
+	}
+	if strings.Join(ran, ",") != "first,second" {
+		t.Errorf("hooks run = %v, want [first second]", ran)
+	}
+}
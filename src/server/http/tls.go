@@ -0,0 +1,180 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go-chi-boilerplate/src/config"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTLSReloadInterval = time.Minute
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type (
+	// certReloader serves the certificate (and client CA pool) from disk,
+	// re-reading the files whenever they change so rotated certificates are picked up without a restart
+	certReloader struct {
+		certFile     string
+		keyFile      string
+		clientCAFile string
+		interval     time.Duration
+
+		mu        sync.RWMutex
+		cert      *tls.Certificate
+		clientCAs *x509.CertPool
+		modTime   time.Time
+		lastCheck time.Time
+	}
+)
+
+func newTLSConfig(cfg config.TLS) (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.MinVersion != "" {
+		version, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls min version %q, valid choices are: 1.0, 1.1, 1.2, 1.3", cfg.MinVersion)
+		}
+		minVersion = version
+	}
+
+	interval := time.Second * time.Duration(cfg.ReloadInterval)
+	if interval <= 0 {
+		interval = defaultTLSReloadInterval
+	}
+
+	reloader := &certReloader{
+		certFile:     cfg.CertFile,
+		keyFile:      cfg.KeyFile,
+		clientCAFile: cfg.ClientCAFile,
+		interval:     interval,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+		// enable HTTP/2 with HTTP/1.1 fallback
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	// mTLS
+	if cfg.ClientCAFile != "" {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig := tlsConfig.Clone()
+			clientConfig.GetConfigForClient = nil
+			clientConfig.ClientCAs = reloader.ClientCAs()
+			return clientConfig, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.maybeReload()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *certReloader) ClientCAs() *x509.CertPool {
+	c.maybeReload()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.clientCAs
+}
+
+// maybeReload checks the files at most once per interval and reloads them when any of them changed.
+// A failed reload keeps serving the previous certificate.
+func (c *certReloader) maybeReload() {
+	c.mu.Lock()
+	if time.Since(c.lastCheck) < c.interval {
+		c.mu.Unlock()
+		return
+	}
+	c.lastCheck = time.Now()
+	modTime := c.modTime
+	c.mu.Unlock()
+
+	latest, err := c.latestModTime()
+	if err != nil {
+		logrus.Errorf("error when checking tls files, error: %v", err)
+		return
+	}
+	if !latest.After(modTime) {
+		return
+	}
+
+	if err := c.load(); err != nil {
+		logrus.Errorf("error when reloading tls certificate, keeping the previous one, error: %v", err)
+		return
+	}
+	logrus.Printf("[INFO] tls certificate reloaded from %s", c.certFile)
+}
+
+func (c *certReloader) load() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error when loading tls key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		caPEM, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("error when reading tls client ca: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no valid certificate found in tls client ca %s", c.clientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTime = modTime
+	c.lastCheck = time.Now()
+
+	return nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, fmt.Errorf("error when reading %s: %w", file, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
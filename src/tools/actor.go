@@ -0,0 +1,20 @@
+package tools
+
+import "context"
+
+type actorContextKey struct{}
+
+// WithActor returns a copy of ctx carrying the acting user (e.g. set by the authentication middleware),
+// recorded in the created_by and updated_by audit columns
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// Actor returns the acting user of ctx, empty when unknown
+func Actor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
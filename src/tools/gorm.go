@@ -2,17 +2,106 @@ package tools
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"log"
+	gormlogger "gorm.io/gorm/logger"
+	"regexp"
+	"time"
 )
 
-func NewGormDB(ctx context.Context, dsn string) *gorm.DB {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// gorm renders the numeric placeholders left without a value as $1$
+var gormPlaceholder = regexp.MustCompile(`\$(\d+)\$`)
+
+type (
+	// GormLogger routes the gorm logs to logrus and its statements to a QueryLog, without their bound parameters.
+	// The levels are the ones of logrus, LogMode is a no-op.
+	GormLogger struct {
+		queryLog   *QueryLog
+		datasource string
+	}
+)
+
+// NewGormLogger builds a gorm logger naming the statements after datasource, queryLog nil leaves them unlogged
+func NewGormLogger(queryLog *QueryLog, datasource string) *GormLogger {
+	return &GormLogger{
+		queryLog:   queryLog,
+		datasource: datasource,
+	}
+}
+
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	logrus.WithContext(ctx).Infof(msg, data...)
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	logrus.WithContext(ctx).Warnf(msg, data...)
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	logrus.WithContext(ctx).Errorf(msg, data...)
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.queryLog == nil {
+		return
+	}
+
+	// not finding a record is a result, not a failure
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+
+	statement, rows := fc()
+	l.queryLog.Log(ctx, Query{
+		Datasource: l.datasource,
+		Statement:  gormPlaceholder.ReplaceAllString(statement, "$$$1"),
+		Duration:   time.Since(begin),
+		Rows:       rows,
+		Err:        err,
+	})
+}
+
+// ParamsFilter drops the bound parameters, so the statements are logged with their placeholders
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// NewGormDB wraps db with the gorm dialector of driver, its pool settings are left untouched and it is not pinged (see NewSqlDB)
+func NewGormDB(ctx context.Context, driver string, db *sql.DB, logger gormlogger.Interface) (*gorm.DB, error) {
+	gormDB, err := gorm.Open(gormDialector(driver, db), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger,
+	})
 	if err != nil {
-		log.Fatalf("error when NewGormDB, error: %s\n", err.Error())
-		return nil
+		return nil, fmt.Errorf("error when NewGormDB, error: %w", err)
 	}
 
-	return db.WithContext(ctx)
+	return gormDB.WithContext(ctx), nil
+}
+
+func gormDialector(driver string, db *sql.DB) gorm.Dialector {
+	switch SqlDialect(driver) {
+	case DIALECT_MYSQL:
+		return mysql.New(mysql.Config{Conn: db})
+	case DIALECT_SQLITE:
+		return &sqlite.Dialector{Conn: db}
+	}
+	return postgres.New(postgres.Config{Conn: db})
+}
+
+// GormWithTx returns a gorm session running its queries in tx (e.g. the *sql.Tx of a sqlx transaction)
+func GormWithTx(ctx context.Context, db *gorm.DB, tx *sql.Tx) *gorm.DB {
+	session := db.WithContext(ctx)
+	session.Statement.ConnPool = tx
+	return session
 }
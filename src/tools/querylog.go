@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type (
+	// QueryLog logs the statements run against the datasources with their duration, rows affected and request id:
+	// at debug level, warn level when slower than SlowThreshold (0 never flags them) and error level when they fail.
+	// Statements are logged with their placeholders, the bound parameters are never logged.
+	QueryLog struct {
+		SlowThreshold time.Duration
+	}
+
+	// Query is a statement run against a datasource, Rows is -1 when unknown (e.g. rows read by a cursor)
+	Query struct {
+		Datasource string
+		Statement  string
+		Duration   time.Duration
+		Rows       int64
+		Err        error
+	}
+)
+
+func NewQueryLog(slowThreshold time.Duration) *QueryLog {
+	return &QueryLog{SlowThreshold: slowThreshold}
+}
+
+func (q *QueryLog) Log(ctx context.Context, query Query) {
+	slow := q.SlowThreshold > 0 && query.Duration >= q.SlowThreshold
+	if query.Err == nil && !slow && !logrus.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	fields := logrus.Fields{
+		"datasource":  query.Datasource,
+		"duration_ms": float64(query.Duration.Microseconds()) / 1000,
+	}
+	if query.Rows >= 0 {
+		fields["rows"] = query.Rows
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	log := logrus.WithContext(ctx).WithFields(fields)
+
+	// one line per statement, whatever the formatting of the query
+	statement := strings.Join(strings.Fields(query.Statement), " ")
+	switch {
+	case query.Err != nil:
+		log.WithError(query.Err).Errorf("query failed: %s", statement)
+	case slow:
+		log.Warnf("slow query (over %s): %s", q.SlowThreshold, statement)
+	default:
+		log.Debugf("query: %s", statement)
+	}
+}
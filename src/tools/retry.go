@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math/rand"
+	"time"
+)
+
+type (
+	// Backoff is an exponential backoff with jitter, MaxAttempts 0 retries until the context is done
+	Backoff struct {
+		MaxAttempts     int
+		InitialInterval time.Duration
+		MaxInterval     time.Duration
+	}
+)
+
+// Delay returns the wait before the given retry (1 for the first retry),
+// a random duration between half and the whole of the exponential interval, capped by MaxInterval
+func (b Backoff) Delay(retry int) time.Duration {
+	interval := b.InitialInterval
+	for i := 1; i < retry && interval < b.MaxInterval; i++ {
+		interval *= 2
+	}
+	if b.MaxInterval > 0 && interval > b.MaxInterval {
+		interval = b.MaxInterval
+	}
+	if interval <= 0 {
+		return 0
+	}
+
+	half := interval / 2
+	return half + time.Duration(rand.Int63n(int64(interval-half)+1))
+}
+
+// Retry calls fn until it succeeds, the attempts are exhausted or ctx is done, returning the last error
+func Retry(ctx context.Context, name string, backoff Backoff, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if backoff.MaxAttempts > 0 && attempt >= backoff.MaxAttempts {
+			return fmt.Errorf("error when connecting %s after %d attempts, error: %w", name, attempt, err)
+		}
+
+		delay := backoff.Delay(attempt)
+		logrus.Warnf("attempt %d to connect %s failed, retrying in %s, error: %v", attempt, name, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("error when connecting %s, error: %w", name, err)
+		}
+	}
+}
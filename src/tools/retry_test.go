@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		retry   int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "first retry", backoff: Backoff{InitialInterval: time.Second, MaxInterval: time.Minute}, retry: 1, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "doubled", backoff: Backoff{InitialInterval: time.Second, MaxInterval: time.Minute}, retry: 3, wantMin: 2 * time.Second, wantMax: 4 * time.Second},
+		{name: "capped", backoff: Backoff{InitialInterval: time.Second, MaxInterval: 5 * time.Second}, retry: 10, wantMin: 2500 * time.Millisecond, wantMax: 5 * time.Second},
+		{name: "no interval", backoff: Backoff{}, retry: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := tt.backoff.Delay(tt.retry); got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("Delay(%d) = %s, want between %s and %s", tt.retry, got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errFailed := errors.New("failed")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		backoff      Backoff
+		failures     int
+		wantAttempts int
+		wantErr      string
+	}{
+		{name: "first attempt", ctx: context.Background(), backoff: Backoff{MaxAttempts: 3}, wantAttempts: 1},
+		{name: "after failures", ctx: context.Background(), backoff: Backoff{MaxAttempts: 3}, failures: 2, wantAttempts: 3},
+		{name: "attempts exhausted", ctx: context.Background(), backoff: Backoff{MaxAttempts: 3}, failures: 5, wantAttempts: 3, wantErr: "after 3 attempts"},
+		{name: "unlimited attempts", ctx: context.Background(), failures: 5, wantAttempts: 6},
+		{name: "context done", ctx: canceled, backoff: Backoff{InitialInterval: time.Hour}, failures: 5, wantAttempts: 1, wantErr: "error when connecting test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := Retry(tt.ctx, "test", tt.backoff, func(ctx context.Context) error {
+				attempts++
+				if attempts <= tt.failures {
+					return errFailed
+				}
+				return nil
+			})
+			if tt.wantErr != "" {
+				if !errors.Is(err, errFailed) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Retry() error = %v, want %q wrapping %v", err, tt.wantErr, errFailed)
+				}
+			} else if err != nil {
+				t.Errorf("Retry() error = %v", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/glebarez/go-sqlite"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// NewSqlDB opens a database/sql pool, meant to be shared by the sqlx and gorm handles
+func NewSqlDB(ctx context.Context, driver, dsn string, pool SqlPoolConfig) (*sql.DB, error) {
+	log := logrus.WithContext(ctx)
+
+	db, err := OpenSqlDB(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error when sql.Open, error: %w", err)
+	}
+
+	// Setup Connection
+	SetupSqlPool(db, pool)
+
+	log.Printf("ping %s", driver)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error when ping %s, error: %w", driver, err)
+	}
+
+	return db, nil
+}
+
+// OpenSqlDB opens a database/sql pool of driver (postgres, pgx, mysql or sqlite) without connecting it
+func OpenSqlDB(driver, dsn string) (*sql.DB, error) {
+	return sql.Open(driver, dsn)
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"errors"
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strings"
+)
+
+// SQL dialects, the drivers postgres (lib/pq) and pgx (jackc/pgx) both speak DIALECT_POSTGRES
+const (
+	DIALECT_POSTGRES = "postgres"
+	DIALECT_MYSQL    = "mysql"
+	DIALECT_SQLITE   = "sqlite"
+
+	mysqlErrorDuplicateEntry  = 1062
+	mysqlErrorLockWaitTimeout = 1205
+	mysqlErrorDeadlock        = 1213
+)
+
+// SqlDialect returns the dialect spoken by driver
+func SqlDialect(driver string) string {
+	switch driver {
+	case DIALECT_MYSQL:
+		return DIALECT_MYSQL
+	case DIALECT_SQLITE:
+		return DIALECT_SQLITE
+	}
+	return DIALECT_POSTGRES
+}
+
+// QuoteSqlIdentifier quotes a table or column name for dialect, schema qualified or not (e.g. public.examples)
+func QuoteSqlIdentifier(dialect, name string) string {
+	quote := `"`
+	if dialect == DIALECT_MYSQL {
+		quote = "`"
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// IsSqlUniqueViolation reports whether err is a mysql duplicate entry or a sqlite unique constraint failure
+// (postgres reports it with its SQLSTATE)
+func IsSqlUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrorDuplicateEntry
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+// IsSqlLockFailure reports whether err is a mysql deadlock or lock wait timeout, or a busy or locked sqlite database,
+// errors a transaction can succeed after when retried (postgres reports them with their SQLSTATE)
+func IsSqlLockFailure(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrorDeadlock || mysqlErr.Number == mysqlErrorLockWaitTimeout
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// the primary result code is the low byte of the extended one (e.g. SQLITE_BUSY_SNAPSHOT)
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
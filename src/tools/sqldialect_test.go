@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteErrors(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10)"
+	db, err := NewSqlDB(ctx, DIALECT_SQLITE, dsn, SqlPoolConfig{MaxOpenConns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE examples (id INTEGER PRIMARY KEY, name TEXT UNIQUE)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO examples (id, name) VALUES (1, 'a')"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantUnique bool
+	}{
+		{name: "duplicate unique column", query: "INSERT INTO examples (id, name) VALUES (2, 'a')", wantUnique: true},
+		{name: "duplicate primary key", query: "INSERT INTO examples (id, name) VALUES (1, 'b')", wantUnique: true},
+		{name: "not null", query: "CREATE TABLE t (v TEXT NOT NULL); INSERT INTO t (v) VALUES (NULL)"},
+		{name: "syntax error", query: "INSERT INTO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.ExecContext(ctx, tt.query)
+			if err == nil {
+				t.Fatal("query succeeded")
+			}
+			if got := IsSqlUniqueViolation(err); got != tt.wantUnique {
+				t.Errorf("IsSqlUniqueViolation(%v) = %v, want %v", err, got, tt.wantUnique)
+			}
+			if IsSqlLockFailure(err) {
+				t.Errorf("IsSqlLockFailure(%v) = true, want false", err)
+			}
+		})
+	}
+
+	// a second writer gives up on the lock held by the first one after the busy timeout
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO examples (id, name) VALUES (3, 'c')"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO examples (id, name) VALUES (4, 'd')")
+	if !IsSqlLockFailure(err) {
+		t.Errorf("IsSqlLockFailure(%v) = false, want true", err)
+	}
+}
+
+func TestSqlDialect(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{driver: "postgres", want: DIALECT_POSTGRES},
+		{driver: "pgx", want: DIALECT_POSTGRES},
+		{driver: "mysql", want: DIALECT_MYSQL},
+		{driver: "sqlite", want: DIALECT_SQLITE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			if got := SqlDialect(tt.driver); got != tt.want {
+				t.Errorf("SqlDialect(%q) = %q, want %q", tt.driver, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteSqlIdentifier(t *testing.T) {
+	tests := []struct {
+		dialect string
+		name    string
+		want    string
+	}{
+		{dialect: DIALECT_POSTGRES, name: "public.examples", want: `"public"."examples"`},
+		{dialect: DIALECT_SQLITE, name: `we"ird`, want: `"we""ird"`},
+		{dialect: DIALECT_MYSQL, name: "examples", want: "`examples`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteSqlIdentifier(tt.dialect, tt.name); got != tt.want {
+				t.Errorf("QuoteSqlIdentifier(%q, %q) = %s, want %s", tt.dialect, tt.name, got, tt.want)
+			}
+		})
+	}
+}
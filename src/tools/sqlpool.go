@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"database/sql"
+	"time"
+)
+
+type (
+	// SqlPoolConfig configures a database/sql connection pool, zero durations mean unlimited
+	SqlPoolConfig struct {
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		ConnMaxIdleTime time.Duration
+	}
+)
+
+// SetupSqlPool applies the pool config to db
+func SetupSqlPool(db *sql.DB, pool SqlPoolConfig) {
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+}
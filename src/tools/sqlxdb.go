@@ -2,30 +2,84 @@ package tools
 
 import (
 	"context"
+	"database/sql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
-	"github.com/sirupsen/logrus"
 	"time"
 )
 
-func NewSqlxDsn(ctx context.Context, driver, dsn string) *sqlx.DB {
-	log := logrus.WithContext(ctx)
+type (
+	// queryLogSqlx logs the statements run through a sqlx pool or transaction
+	queryLogSqlx struct {
+		sqlx.ExtContext
+		queryLog   *QueryLog
+		datasource string
+	}
+)
+
+// the pure-Go sqlite driver registers as sqlite, unknown to sqlx
+func init() {
+	sqlx.BindDriver(DIALECT_SQLITE, sqlx.QUESTION)
+}
 
-	db, err := sqlx.Open(driver, dsn)
-	if err != nil {
-		log.Fatalf("error when sqlx.Connect, error: %s\n", err.Error())
-		return nil
+// NewSqlxDB wraps db, driver picks the bind variables of the queries (e.g. $1 for postgres and pgx, ? for mysql and sqlite)
+func NewSqlxDB(db *sql.DB, driver string) *sqlx.DB {
+	return sqlx.NewDb(db, driver)
+}
+
+// SqlxWithQueryLog logs the statements of db to queryLog under datasource, db is returned as is when queryLog is nil
+func SqlxWithQueryLog(db sqlx.ExtContext, queryLog *QueryLog, datasource string) sqlx.ExtContext {
+	if queryLog == nil {
+		return db
 	}
+	return &queryLogSqlx{
+		ExtContext: db,
+		queryLog:   queryLog,
+		datasource: datasource,
+	}
+}
+
+func (q *queryLogSqlx) log(ctx context.Context, statement string, begin time.Time, rows int64, err error) {
+	q.queryLog.Log(ctx, Query{
+		Datasource: q.datasource,
+		Statement:  statement,
+		Duration:   time.Since(begin),
+		Rows:       rows,
+		Err:        err,
+	})
+}
+
+func (q *queryLogSqlx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	begin := time.Now()
+	rows, err := q.ExtContext.QueryContext(ctx, query, args...)
+	q.log(ctx, query, begin, -1, err)
+	return rows, err
+}
+
+func (q *queryLogSqlx) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	begin := time.Now()
+	rows, err := q.ExtContext.QueryxContext(ctx, query, args...)
+	q.log(ctx, query, begin, -1, err)
+	return rows, err
+}
+
+func (q *queryLogSqlx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	begin := time.Now()
+	row := q.ExtContext.QueryRowxContext(ctx, query, args...)
+	q.log(ctx, query, begin, -1, row.Err())
+	return row
+}
 
-	// Setup Connection
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+func (q *queryLogSqlx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	begin := time.Now()
+	result, err := q.ExtContext.ExecContext(ctx, query, args...)
 
-	log.Printf("ping %s", driver)
-	if err := db.Ping(); err != nil {
-		log.Fatalf("error when ping %s, error: %s\n", driver, err.Error())
+	rows := int64(-1)
+	if err == nil {
+		if affected, affectedErr := result.RowsAffected(); affectedErr == nil {
+			rows = affected
+		}
 	}
+	q.log(ctx, query, begin, rows, err)
 
-	return db
+	return result, err
 }
@@ -2,6 +2,7 @@ package httputils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/audricimanuel/errorutils"
 	"go-chi-boilerplate/utils"
@@ -62,6 +63,20 @@ func MapBaseResponse(w http.ResponseWriter, r *http.Request, data interface{}, e
 	w.Write(jsonResponse)
 }
 
+// ToHttpError returns the HttpError err is or wraps (e.g. errorutils.ErrorNotFound returned by a repository),
+// an internal server error otherwise
+func ToHttpError(err error) errorutils.HttpError {
+	if err == nil {
+		return nil
+	}
+
+	var httpErr *errorutils.HttpErrorImpl
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return errorutils.ToHttpError(err, http.StatusInternalServerError)
+}
+
 func SetBaseMeta(page int, limit int, totalData int) BaseMeta {
 	totalPage := float64(totalData) / float64(limit)
 	return BaseMeta{
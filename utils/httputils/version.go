@@ -0,0 +1,60 @@
+package httputils
+
+import (
+	"github.com/audricimanuel/errorutils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	VERSION_CONFLICT      = "your item has been modified by another request"
+	PRECONDITION_REQUIRED = "the If-Match header is required"
+)
+
+var (
+	// ErrorVersionConflict is returned by the repositories when the version of an updated item is not the expected one,
+	// MapBaseResponse answers it with 409 Conflict
+	ErrorVersionConflict = errorutils.NewHttpError(http.StatusConflict, VERSION_CONFLICT)
+	// ErrorPreconditionRequired is returned by RequireIfMatch when the request has no If-Match header
+	ErrorPreconditionRequired = errorutils.NewHttpError(http.StatusPreconditionRequired, PRECONDITION_REQUIRED)
+)
+
+// IfMatch returns the version expected by the If-Match header ("3" or W/"3"), nil when the header is missing or *.
+// A malformed header returns errorutils.ErrorBadRequest.
+//
+//	Usage example:
+//		version, err := httputils.IfMatch(r)
+//		if err != nil {
+//			httputils.MapBaseResponse(w, r, nil, err, nil)
+//			return
+//		}
+//		if version != nil {
+//			ctx = repository.WithExpectedVersion(ctx, *version)
+//		}
+func IfMatch(r *http.Request) (*int64, errorutils.HttpError) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, errorutils.ErrorBadRequest
+	}
+	return &version, nil
+}
+
+// RequireIfMatch is IfMatch for the writes that must not overwrite a concurrent change blindly,
+// returning ErrorPreconditionRequired when the header is missing (If-Match: * still skips the version check)
+func RequireIfMatch(r *http.Request) (*int64, errorutils.HttpError) {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		return nil, ErrorPreconditionRequired
+	}
+	return IfMatch(r)
+}
+
+// SetETag exposes the version of the returned item, for the client to send it back with If-Match
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
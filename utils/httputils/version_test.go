@@ -0,0 +1,75 @@
+package httputils
+
+import (
+	"github.com/audricimanuel/errorutils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		want        *int64
+		wantErr     errorutils.HttpError
+		wantRequire errorutils.HttpError
+	}{
+		{name: "missing", wantRequire: ErrorPreconditionRequired},
+		{name: "any version", header: "*"},
+		{name: "strong", header: `"3"`, want: version(3)},
+		{name: "weak", header: `W/"3"`, want: version(3)},
+		{name: "unquoted", header: " 12 ", want: version(12)},
+		{name: "malformed", header: `"abc"`, wantErr: errorutils.ErrorBadRequest, wantRequire: errorutils.ErrorBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			got, err := IfMatch(r)
+			assertVersion(t, "IfMatch", got, err, tt.want, tt.wantErr)
+
+			got, err = RequireIfMatch(r)
+			if tt.wantRequire != nil {
+				assertVersion(t, "RequireIfMatch", got, err, nil, tt.wantRequire)
+			} else {
+				assertVersion(t, "RequireIfMatch", got, err, tt.want, nil)
+			}
+		})
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetETag(w, 7)
+
+	r := httptest.NewRequest(http.MethodPut, "/", nil)
+	r.Header.Set("If-Match", w.Header().Get("ETag"))
+	got, err := IfMatch(r)
+	assertVersion(t, "IfMatch(ETag)", got, err, version(7), nil)
+}
+
+func version(v int64) *int64 {
+	return &v
+}
+
+func assertVersion(t *testing.T, name string, got *int64, err errorutils.HttpError, want *int64, wantErr errorutils.HttpError) {
+	t.Helper()
+	if err != wantErr {
+		t.Errorf("%s() error = %v, want %v", name, err, wantErr)
+	}
+	if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+		t.Errorf("%s() = %s, want %s", name, formatVersion(got), formatVersion(want))
+	}
+}
+
+func formatVersion(v *int64) string {
+	if v == nil {
+		return "nil"
+	}
+	return strconv.FormatInt(*v, 10)
+}
@@ -34,7 +34,7 @@ func getErrorMessage(err validator.FieldError, jsonField string) string {
 	}
 
 	switch err.Tag() {
-	case "required", "required_if":
+	case "required", "required_if", "required_with":
 		return fmt.Sprintf("%s is required", jsonField)
 	case "min":
 		return fmt.Sprintf("%s must be at least %s", jsonField, err.Param())
@@ -42,6 +42,12 @@ func getErrorMessage(err validator.FieldError, jsonField string) string {
 		return fmt.Sprintf("%s must be at most %s", jsonField, err.Param())
 	case "email":
 		return fmt.Sprintf("%s must be a valid email address", jsonField)
+	case "numeric":
+		return fmt.Sprintf("%s must be numeric", jsonField)
+	case "excluded_if":
+		return fmt.Sprintf("%s must be empty when %s", jsonField, strings.Replace(err.Param(), " ", " is ", 1))
+	case "file":
+		return fmt.Sprintf("%s must be an existing file", jsonField)
 	case "oneof":
 		choices := strings.Split(err.Param(), " ")
 		choicesStr := ""
@@ -89,14 +95,7 @@ func ValidatePayload(request *http.Request, s interface{}) error {
 
 // ValidateStruct to validate struct using Go Validator (returning map of error: model.Errors)
 func ValidateStruct(structObj interface{}) error {
-	validatorObj := GetValidatorController()
-
-	// add custom validator to validate field with datetime format (YYYY-MM-DD hh:mm)
-	validatorObj.RegisterValidation("datetimeformat", func(fl validator.FieldLevel) bool {
-		datetimeStr := fl.Field().String()
-		_, err := time.Parse("2006-01-02 15:04", datetimeStr)
-		return err == nil
-	})
+	validatorObj := newValidator()
 
 	if err := validatorObj.Struct(structObj); err != nil {
 		switch err.(type) {
@@ -119,6 +118,102 @@ func ValidateStruct(structObj interface{}) error {
 	return nil
 }
 
+// ValidateStructAll to validate struct using Go Validator, reporting every invalid field at once (joined errors).
+// Fields are named after the given struct tag (e.g. "json", "mapstructure").
+//
+//	Usage example:
+//		if err := ValidateStructAll(cfg, "mapstructure"); err != nil {
+//			return err
+//		}
+func ValidateStructAll(structObj interface{}, tagName string) error {
+	return ValidateStructNamespaces(structObj, tagName)
+}
+
+// ValidateStructNamespaces is ValidateStructAll reporting only the invalid fields within the given struct namespaces
+// (e.g. "Config.DataSource.SqlDBConfig"), every invalid field when none is given
+func ValidateStructNamespaces(structObj interface{}, tagName string, namespaces ...string) error {
+	validatorObj := newValidator()
+
+	err := validatorObj.Struct(structObj)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return errors.New(fmt.Sprintf("payload error: %s", err.Error()))
+	}
+
+	errs := make([]error, 0, len(validationErrors))
+	for _, errorField := range validationErrors {
+		if !inNamespaces(errorField.StructNamespace(), namespaces) {
+			continue
+		}
+		fieldName := getFieldTagByNamespace(reflect.TypeOf(structObj), errorField.StructNamespace(), tagName)
+		errs = append(errs, errors.New(getErrorMessage(errorField, fieldName)))
+	}
+	return errors.Join(errs...)
+}
+
+func inNamespaces(namespace string, namespaces []string) bool {
+	if len(namespaces) == 0 {
+		return true
+	}
+	for _, ns := range namespaces {
+		if namespace == ns || strings.HasPrefix(namespace, ns+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func newValidator() *validator.Validate {
+	validatorObj := GetValidatorController()
+
+	// add custom validator to validate field with datetime format (YYYY-MM-DD hh:mm)
+	validatorObj.RegisterValidation("datetimeformat", func(fl validator.FieldLevel) bool {
+		datetimeStr := fl.Field().String()
+		_, err := time.Parse("2006-01-02 15:04", datetimeStr)
+		return err == nil
+	})
+
+	return validatorObj
+}
+
+// getFieldTagByNamespace to get the tag of the field pointed by the validator namespace (e.g. Config.Host.Port),
+// falling back to the field name when the tag is empty
+func getFieldTagByNamespace(t reflect.Type, namespace string, tagName string) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	parts := strings.Split(namespace, ".")
+	fieldName := parts[len(parts)-1]
+	for _, part := range parts[1:] {
+		if idx := strings.Index(part, "["); idx >= 0 {
+			part = part[:idx]
+		}
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return fieldName
+		}
+		field, ok := t.FieldByName(part)
+		if !ok {
+			return fieldName
+		}
+		if tag := strings.Split(field.Tag.Get(tagName), ",")[0]; tag != "" {
+			fieldName = tag
+		} else {
+			fieldName = field.Name
+		}
+		t = field.Type
+	}
+
+	return fieldName
+}
+
 // GetJsonTagInStruct to get the JSON tag of struct field
 func GetJsonTagInStruct(fieldName string, structOfField any) string {
 	res, err := getFieldJSONTagRecursive(reflect.ValueOf(structOfField).Type(), fieldName)